@@ -5,6 +5,7 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"io"
 	"reflect"
 	"time"
 )
@@ -13,16 +14,35 @@ var (
 	endianness = binary.BigEndian
 )
 
-type Decoder struct{}
+// DefaultMaxDepth bounds nesting when Decoder.MaxDepth is not set
+const DefaultMaxDepth = 64
+
+// Decoder never trusts the encoded data: lengths and counts are checked against the remaining bytes
+// before allocating, truncated data fails with io.ErrUnexpectedEOF, and kinds that cannot be stored
+// in the destination fail with KindMismatchError.
+type Decoder struct {
+	Reflective bool // Ignore generated Unmarshaler implementations
+	MaxDepth   int  // Limit on nested values, DefaultMaxDepth if 0
+
+	depth int
+}
 
 func (dec *Decoder) UnmarshallFromInterface(src interface{}, dest interface{}) error {
 	/*
 		- When encoded by Encoder, interface fields are left as bytes
 		- When Decoder decodes structs/maps with these fields, they are left as bytes (see unmarshallInterface)
+		- Nil interfaces are decoded as nil, so src may also be nil
 	*/
-	if reflect.ValueOf(dest).Kind() != reflect.Ptr {
+	destRv := reflect.ValueOf(dest)
+	if destRv.Kind() != reflect.Ptr {
 		return errors.New("dest is not a ptr")
 	}
+	if destRv.IsNil() {
+		return errors.New("dest is a nil ptr")
+	}
+	if src == nil {
+		return dec.unmarshallNil(dest)
+	}
 
 	bytes, ok := src.([]byte)
 	if !ok {
@@ -33,20 +53,51 @@ func (dec *Decoder) UnmarshallFromInterface(src interface{}, dest interface{}) e
 }
 
 func (dec *Decoder) Unmarshall(data []byte, dest interface{}) error {
-	if reflect.ValueOf(dest).Kind() != reflect.Ptr {
+	destRv := reflect.ValueOf(dest)
+	if destRv.Kind() != reflect.Ptr {
 		return errors.New("dest is not a ptr")
 	}
+	if destRv.IsNil() {
+		return errors.New("dest is a nil ptr")
+	}
+
+	// Track depth on a copy so that a Decoder can be shared between goroutines
+	state := *dec
+	state.depth = 0
 	buf := bytes.NewBuffer(data)
-	return dec.unmarshall(buf, dest)
+	return state.unmarshall(buf, dest)
 }
 
 func (dec *Decoder) unmarshall(buf *bytes.Buffer, dest interface{}) error {
+	maxDepth := dec.MaxDepth
+	if maxDepth <= 0 {
+		maxDepth = DefaultMaxDepth
+	}
+	if dec.depth >= maxDepth {
+		return &DepthError{MaxDepth: maxDepth}
+	}
+	dec.depth++
+	defer func() { dec.depth-- }()
+
+	if u, ok := dest.(Unmarshaler); ok && !dec.Reflective {
+		return u.UnmarshalCodec(&Reader{dec: dec, buf: buf})
+	}
+
 	kindVal, err := buf.ReadByte()
 	if err != nil {
-		return err
+		return io.ErrUnexpectedEOF
 	}
 
 	kind := reflect.Kind(kindVal)
+	if kind == Nil {
+		return dec.unmarshallNil(dest)
+	}
+
+	destType := derefType(reflect.TypeOf(dest).Elem())
+	if kind != reflect.Ptr && !decodable(kind, destType) {
+		return &KindMismatchError{Kind: kind, Dest: destType}
+	}
+
 	switch kind {
 	case reflect.Ptr:
 		return dec.unmarshallPtr(buf, dest)
@@ -62,27 +113,43 @@ func (dec *Decoder) unmarshall(buf *bytes.Buffer, dest interface{}) error {
 		return dec.unmarshallIterable(buf, dest)
 	case reflect.String:
 		return dec.unmarshallString(buf, dest)
-	case reflect.Bool,
-		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
 		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
-		reflect.Float32, reflect.Float64,
-		reflect.Complex64, reflect.Complex128:
-		return dec.unmarshallNumber(buf, dest)
-	case reflect.Invalid,
+		reflect.Float32, reflect.Float64:
+		return dec.unmarshallNumber(buf, kind, dest)
+	case reflect.Bool,
+		reflect.Complex64, reflect.Complex128,
+		reflect.Invalid,
 		reflect.Uintptr, reflect.UnsafePointer,
 		reflect.Chan, reflect.Func:
 		return fmt.Errorf("unable to decode kind %d", kind)
 	default:
-		return fmt.Errorf("unknown kind %d", kind)
+		return &UnknownKindError{Kind: kindVal}
+	}
+}
+
+func (dec *Decoder) unmarshallNil(dest interface{}) error {
+	// Format: [kind (8-bit)]
+	// Leave dest nil instead of allocating, only types that can be nil accept it
+	destRv := reflect.ValueOf(dest).Elem()
+	switch destRv.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Map, reflect.Interface:
+		destRv.Set(reflect.Zero(destRv.Type()))
+		return nil
+	default:
+		return &KindMismatchError{Kind: Nil, Dest: destRv.Type()}
 	}
 }
 
 func (dec *Decoder) unmarshallPtr(buf *bytes.Buffer, dest interface{}) error {
 	// dest is at least **type
-	destRv := reflect.ValueOf(dest).Elem()
+	destType := reflect.TypeOf(dest).Elem()
+	if destType.Kind() != reflect.Ptr {
+		return &KindMismatchError{Kind: reflect.Ptr, Dest: destType}
+	}
 
-	dereferencedType := reflect.TypeOf(dest).Elem().Elem()
-	destRv.Set(reflect.New(dereferencedType)) // Initialise address
+	destRv := reflect.ValueOf(dest).Elem()
+	destRv.Set(reflect.New(destType.Elem())) // Initialise address
 	return dec.unmarshall(buf, destRv.Interface())
 }
 
@@ -93,79 +160,48 @@ func (dec *Decoder) unmarshallTime(buf *bytes.Buffer, dest interface{}) error {
 		return err
 	}
 
-	timeData := time.UnixMilli(timeUnixData)
-	timePtrRv := reflect.ValueOf(dest)
-	timeRv := timePtrRv.Elem()
-
-	// Go to dereferenced value and initialise
-	destType := reflect.TypeOf(dest).Elem()
-	if destType.Kind() == reflect.Ptr {
-		for destType.Kind() == reflect.Ptr {
-			destType = destType.Elem()
-		}
-		timePtrRv.Elem().Set(reflect.New(destType))
-
-		timeRv = timePtrRv.Elem()
-		for timeRv.Kind() == reflect.Ptr {
-			timeRv = timeRv.Elem()
-		}
-	}
-
-	timeRv.Set(reflect.ValueOf(timeData))
+	timeRv := dec.initDest(dest)
+	timeRv.Set(reflect.ValueOf(time.UnixMilli(timeUnixData)))
 	return nil
 }
 
 func (dec *Decoder) unmarshallInterface(buf *bytes.Buffer, dest interface{}) error {
 	// Format: [kind (8-bit)][#bytes(8-bit) for length][length][value]
-	dataLength, err := dec.readLength64(buf)
+	dataLength, err := dec.readBoundedLength(buf)
 	if err != nil {
 		return err
 	}
 
 	data := make([]byte, dataLength)
-	_, err = buf.Read(data)
-	if err != nil {
-		return err
-	}
+	copy(data, buf.Next(int(dataLength)))
 
-	destRv := reflect.ValueOf(dest).Elem()
+	destRv := dec.initDest(dest)
 	destRv.Set(reflect.ValueOf(data))
 	return nil
 }
 
 func (dec *Decoder) unmarshallStruct(buf *bytes.Buffer, dest interface{}) error {
 	// Format: [kind (8-bit)][#bytes(8-bit) for #fields][#fields][field-value pairs]
-	numFields, err := dec.readLength64(buf)
+	numFields, err := dec.readBoundedLength(buf)
 	if err != nil {
 		return err
 	}
 
-	structPtrRv := reflect.ValueOf(dest)
-	structRv := structPtrRv.Elem()
-
-	// Go to dereferenced value and initialise
-	destType := reflect.TypeOf(dest).Elem()
-	if destType.Kind() == reflect.Ptr {
-		for destType.Kind() == reflect.Ptr {
-			destType = destType.Elem()
-		}
-		structPtrRv.Elem().Set(reflect.New(destType))
-
-		structRv = structPtrRv.Elem()
-		for structRv.Kind() == reflect.Ptr {
-			structRv = structRv.Elem()
-		}
+	structRv := dec.initDest(dest)
+	info, err := getStructInfo(structRv.Type())
+	if err != nil {
+		return err
 	}
 
 	for idx := 0; idx < int(numFields); idx++ {
-		// Unmarshall field name
-		var fieldName string
-		if err := dec.unmarshall(buf, &fieldName); err != nil {
+		// Unmarshall field key, either a name or a numeric ID
+		field, err := dec.unmarshallFieldKey(buf, structRv.Type(), info)
+		if err != nil {
 			return err
 		}
 
 		// Initialise and unmarshall field value
-		structFieldRv := structRv.FieldByName(fieldName)
+		structFieldRv := structRv.Field(field.index)
 		structFieldType := structFieldRv.Type()
 		valuePtrRv := reflect.New(structFieldType)
 
@@ -181,6 +217,43 @@ func (dec *Decoder) unmarshallStruct(buf *bytes.Buffer, dest interface{}) error
 	return nil
 }
 
+func (dec *Decoder) unmarshallFieldKey(buf *bytes.Buffer, structType reflect.Type, info *structInfo) (fieldInfo, error) {
+	if buf.Len() == 0 {
+		return fieldInfo{}, io.ErrUnexpectedEOF
+	}
+
+	if reflect.Kind(buf.Bytes()[0]) == reflect.String {
+		var fieldName string
+		if err := dec.unmarshall(buf, &fieldName); err != nil {
+			return fieldInfo{}, err
+		}
+
+		pos, ok := info.byName[fieldName]
+		if !ok {
+			return fieldInfo{}, &UnknownFieldError{Struct: structType.String(), Name: fieldName}
+		}
+		return info.fields[pos], nil
+	}
+
+	switch reflect.Kind(buf.Bytes()[0]) {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+	default:
+		return fieldInfo{}, fmt.Errorf("invalid field key kind %d in %s", buf.Bytes()[0], structType)
+	}
+
+	var fieldID int
+	if err := dec.unmarshall(buf, &fieldID); err != nil {
+		return fieldInfo{}, err
+	}
+
+	pos, ok := info.byID[fieldID]
+	if !ok {
+		return fieldInfo{}, &UnknownFieldError{Struct: structType.String(), ID: fieldID}
+	}
+	return info.fields[pos], nil
+}
+
 func (dec *Decoder) unmarshallMap(buf *bytes.Buffer, dest interface{}) error {
 	// Format: [kind (8-bit)][#bytes(8-bit) for #kv-pairs][#kv-pairs][kv-pairs]
 	/*
@@ -189,28 +262,12 @@ func (dec *Decoder) unmarshallMap(buf *bytes.Buffer, dest interface{}) error {
 		of extra encoding data (kind, length, etc.)
 	*/
 
-	numPairs, err := dec.readLength64(buf)
+	numPairs, err := dec.readBoundedLength(buf)
 	if err != nil {
 		return err
 	}
 
-	mapPtrRv := reflect.ValueOf(dest)
-	mapRv := mapPtrRv.Elem()
-
-	// Go to dereferenced value and initialise
-	destType := reflect.TypeOf(dest).Elem()
-	if destType.Kind() == reflect.Ptr {
-		for destType.Kind() == reflect.Ptr {
-			destType = destType.Elem()
-		}
-		mapPtrRv.Elem().Set(reflect.New(destType))
-
-		mapRv = mapPtrRv.Elem()
-		for mapRv.Kind() == reflect.Ptr {
-			mapRv = mapRv.Elem()
-		}
-	}
-
+	mapRv := dec.initDest(dest)
 	mapType := mapRv.Type()
 	keyType := mapType.Key()
 	valueType := mapType.Elem()
@@ -233,7 +290,11 @@ func (dec *Decoder) unmarshallMap(buf *bytes.Buffer, dest interface{}) error {
 			return err
 		}
 
+		// Keys left as bytes in an interface cannot be hashed
 		keyRv := keyPtrRv.Elem()
+		if !keyRv.Comparable() {
+			return fmt.Errorf("map key of type %s is not comparable", keyRv.Type())
+		}
 		valueRv := valuePtrRv.Elem()
 		mapRv.SetMapIndex(keyRv, valueRv)
 	}
@@ -243,36 +304,23 @@ func (dec *Decoder) unmarshallMap(buf *bytes.Buffer, dest interface{}) error {
 
 func (dec *Decoder) unmarshallIterable(buf *bytes.Buffer, dest interface{}) error {
 	// Format: [kind (8-bit)][#bytes(8-bit) for length][length][value]
-	numItems, err := dec.readLength64(buf)
+	numItems, err := dec.readBoundedLength(buf)
 	if err != nil {
 		return err
 	}
 
-	iterablePtrRv := reflect.ValueOf(dest)
-	iterableRv := iterablePtrRv.Elem()
-	itemType := iterableRv.Type()
-
-	// Go to dereferenced value and initialise
-	destType := reflect.TypeOf(dest).Elem()
-	if destType.Kind() == reflect.Ptr {
-		for destType.Kind() == reflect.Ptr {
-			destType = destType.Elem()
-		}
-		iterablePtrRv.Elem().Set(reflect.New(destType))
-
-		iterableRv = iterablePtrRv.Elem()
-		for iterableRv.Kind() == reflect.Ptr {
-			iterableRv = iterableRv.Elem()
+	// Initialise to avoid out-of-bounds error, arrays already have their size
+	iterableRv := dec.initDest(dest)
+	if iterableRv.Kind() == reflect.Array {
+		if int(numItems) > iterableRv.Len() {
+			return fmt.Errorf("%d items do not fit in %s", numItems, iterableRv.Type())
 		}
-
-		itemType = iterableRv.Type()
+	} else {
+		size := int(numItems)
+		newSizedSlice := reflect.MakeSlice(iterableRv.Type(), size, size)
+		iterableRv.Set(newSizedSlice)
 	}
 
-	// Initialise to avoid out-of-bounds error
-	size := int(numItems)
-	newSizedSlice := reflect.MakeSlice(itemType, size, size)
-	iterableRv.Set(newSizedSlice)
-
 	for idx := 0; idx < int(numItems); idx++ {
 		// Unmarshall item
 		itemRv := iterableRv.Index(idx)
@@ -288,69 +336,34 @@ func (dec *Decoder) unmarshallIterable(buf *bytes.Buffer, dest interface{}) erro
 
 func (dec *Decoder) unmarshallString(buf *bytes.Buffer, dest interface{}) error {
 	// Format: [kind (8-bit)][#bytes(8-bit) for length][length][value]
-	length, err := dec.readLength64(buf)
+	length, err := dec.readBoundedLength(buf)
 	if err != nil {
 		return err
 	}
 
-	strDataBytes := make([]byte, length)
-	_, err = buf.Read(strDataBytes)
-	if err != nil {
-		return err
-	}
-
-	strPtrRv := reflect.ValueOf(dest)
-	strRv := strPtrRv.Elem()
-
-	// Go to dereferenced value and initialise
-	destType := reflect.TypeOf(dest).Elem()
-	if destType.Kind() == reflect.Ptr {
-		for destType.Kind() == reflect.Ptr {
-			destType = destType.Elem()
-		}
-		strPtrRv.Elem().Set(reflect.New(destType))
-
-		strRv = strPtrRv.Elem()
-		for strRv.Kind() == reflect.Ptr {
-			strRv = strRv.Elem()
-		}
-	}
+	strDataBytes := buf.Next(int(length))
+	strRv := dec.initDest(dest)
 
 	// Dealing with aliases
 	strRv.Set(reflect.ValueOf(string(strDataBytes)).Convert(strRv.Type()))
 	return nil
 }
 
-func (dec *Decoder) unmarshallNumber(buf *bytes.Buffer, dest interface{}) error {
+func (dec *Decoder) unmarshallNumber(buf *bytes.Buffer, kind reflect.Kind, dest interface{}) error {
 	// Format: [kind (8-bit)][#bytes (8-bit)][value]
-	numByteForNumVal, err := buf.ReadByte()
+	data, err := dec.readNumberBytes(buf)
 	if err != nil {
 		return err
 	}
+	numByteForNumVal := len(data)
 
-	data := make([]byte, numByteForNumVal)
-	_, err = buf.Read(data)
-	if err != nil {
-		return err
+	isFloat := kind == reflect.Float32 || kind == reflect.Float64
+	if isFloat && numByteForNumVal != 4 && numByteForNumVal != 8 {
+		return fmt.Errorf("invalid float size %d", numByteForNumVal)
 	}
 
-	numPtrRv := reflect.ValueOf(dest)
-	destType := reflect.TypeOf(dest).Elem()
-	numRv := numPtrRv.Elem()
-
-	// Go to dereferenced value and initialise
-	if destType.Kind() == reflect.Ptr {
-		for destType.Kind() == reflect.Ptr {
-			destType = destType.Elem()
-		}
-
-		numPtrRv.Elem().Set(reflect.New(destType))
-
-		numRv = numPtrRv.Elem()
-		for numRv.Kind() == reflect.Ptr {
-			numRv = numRv.Elem()
-		}
-	}
+	numRv := dec.initDest(dest)
+	destType := numRv.Type()
 
 	// Deal with different type in encoded data and dest type
 	var finalVal interface{}
@@ -376,9 +389,9 @@ func (dec *Decoder) unmarshallNumber(buf *bytes.Buffer, dest interface{}) error
 	} else {
 		// Reduce/Pad integer values
 		destSize := destType.Bits() / 8
-		if destSize < int(numByteForNumVal) {
+		if destSize < numByteForNumVal {
 			data = data[len(data)-destSize:]
-		} else if destSize > int(numByteForNumVal) {
+		} else if destSize > numByteForNumVal {
 			// Pad with 0 (pos) or 255 (neg)
 			pad := make([]byte, destSize-len(data))
 			if data[0] >= 128 {
@@ -408,14 +421,35 @@ func (dec *Decoder) unmarshallNumber(buf *bytes.Buffer, dest interface{}) error
 	return nil
 }
 
-func (dec *Decoder) readLength64(buf *bytes.Buffer) (int64, error) {
-	numBytesForLength, err := buf.ReadByte()
+// Allocates any pointers in dest and returns the value to decode into
+func (dec *Decoder) initDest(dest interface{}) reflect.Value {
+	rv := reflect.ValueOf(dest).Elem()
+	for rv.Kind() == reflect.Ptr {
+		rv.Set(reflect.New(rv.Type().Elem()))
+		rv = rv.Elem()
+	}
+	return rv
+}
+
+// Reads [#bytes (8-bit)][value] of a number, rejecting sizes the Encoder never produces
+func (dec *Decoder) readNumberBytes(buf *bytes.Buffer) ([]byte, error) {
+	size, err := buf.ReadByte()
 	if err != nil {
-		return 0, nil
+		return nil, io.ErrUnexpectedEOF
+	}
+	if size == 0 || size > 8 {
+		return nil, fmt.Errorf("invalid number size %d", size)
 	}
 
-	bytesForLength := make([]byte, numBytesForLength)
-	_, err = buf.Read(bytesForLength)
+	data := buf.Next(int(size))
+	if len(data) != int(size) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return data, nil
+}
+
+func (dec *Decoder) readLength64(buf *bytes.Buffer) (int64, error) {
+	bytesForLength, err := dec.readNumberBytes(buf)
 	if err != nil {
 		return 0, err
 	}
@@ -432,6 +466,55 @@ func (dec *Decoder) readLength64(buf *bytes.Buffer) (int64, error) {
 	return num, nil
 }
 
+// Reads a length or count, every byte or item of which takes at least one of the remaining bytes
+func (dec *Decoder) readBoundedLength(buf *bytes.Buffer) (int64, error) {
+	length, err := dec.readLength64(buf)
+	if err != nil {
+		return 0, err
+	}
+	if length < 0 || length > int64(buf.Len()) {
+		return 0, &LengthError{Length: length, Remaining: buf.Len()}
+	}
+	return length, nil
+}
+
+func derefType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+// Whether an encoded kind can be stored in a value of destType
+func decodable(kind reflect.Kind, destType reflect.Type) bool {
+	switch kind {
+	case Time:
+		return reflect.TypeOf(time.Time{}).AssignableTo(destType)
+	case reflect.Interface:
+		return reflect.TypeOf([]byte{}).AssignableTo(destType)
+	case reflect.Struct:
+		return destType.Kind() == reflect.Struct
+	case reflect.Map:
+		return destType.Kind() == reflect.Map
+	case reflect.Array, reflect.Slice:
+		return destType.Kind() == reflect.Array || destType.Kind() == reflect.Slice
+	case reflect.String:
+		return reflect.TypeOf("").ConvertibleTo(destType)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		switch destType.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return true
+		}
+		return false
+	case reflect.Float32, reflect.Float64:
+		return destType.Kind() == reflect.Float32 || destType.Kind() == reflect.Float64
+	default:
+		return true // Left to unmarshall to reject
+	}
+}
+
 func (dec *Decoder) bytesToSizedNum(data []byte, targetType reflect.Type) (interface{}, error) {
 	if targetType.Kind() == reflect.Ptr || targetType.Kind() == reflect.Interface {
 		return nil, errors.New("cannot convert bytes to dest type pointer or interface")
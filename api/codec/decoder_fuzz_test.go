@@ -0,0 +1,91 @@
+package codec_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/chiahsoon/cz4013-client/api"
+	"github.com/chiahsoon/cz4013-client/api/codec"
+	"github.com/chiahsoon/cz4013-client/api/models"
+)
+
+var memo = "rent"
+
+// Valid encodings of the messages exchanged with the server, and of the containers the Decoder supports
+var seeds = []interface{}{
+	api.Request{RSN: 7, ClientID: "0123456789abcdef", AckRSN: 6, Semantic: "at-most-once", Method: string(api.OpenAccountAPI),
+		Data: models.OpenAccountReq{Name: "Alice Tan", Password: "hunter22", InitialBalance: models.NewMoney(10000, "SGD")}, SentAt: time.Unix(1700000000, 0)},
+	api.Request{RSN: 300, Method: string(api.TransferAPI),
+		Data: models.TransferReq{AccountNumber: 1, DestAccountNumber: 2, Name: "Bob", Password: "pw", Amount: models.NewMoney(1250, "USD")}},
+	api.Request{RSN: 301, Method: string(api.TransferAPI),
+		Data: models.TransferReq{AccountNumber: 1, DestAccountNumber: 2, Name: "Bob", Password: "pw", Amount: models.NewMoney(100, "USD"), Memo: &memo}},
+	api.Request{RSN: 302, Method: string(api.QuoteTransferAPI),
+		Data: models.QuoteTransferReq{AccountNumber: 1, Name: "Bob", Password: "pw", Amount: models.NewMoney(1000, "SGD"), DestAccountNumber: 2}},
+	api.Response{RSN: 302, Data: models.QuoteTransferResp{Received: models.NewMoney(740, "USD"), Rate: "0.74"}},
+	api.Request{RSN: 303, Method: string(api.LoginAPI), Data: models.LoginReq{AccountNumber: 1, Name: "Bob", Password: "pw"}},
+	api.Response{RSN: 303, Data: models.SessionResp{Token: "5f0c2a9e4b7d1836a0e9c4f2b1d7e853", ExpiresIn: 300}},
+	api.Request{RSN: 304, Method: string(api.GetBalanceAPI), Token: "5f0c2a9e4b7d1836a0e9c4f2b1d7e853",
+		Data: models.GetBalanceReq{Currency: "SGD"}},
+	api.Response{RSN: 10},
+	map[string]*int{"nil": nil},
+	[]interface{}{nil, []int(nil), map[string]int(nil)},
+	api.Response{RSN: 7, Data: models.OpenAccountResp{Message: "Account 70000 opened"}},
+	api.Response{RSN: -1, ErrMsg: "insufficient balance"},
+	api.Response{RSN: 9, Data: []models.Account{{Number: 1, HolderName: "Alice", Balance: models.NewMoney(1000, "SGD")}, {Number: 2, HolderName: "Bob", Balance: models.NewMoney(-300, "USD")}}},
+	map[string]interface{}{"a": 1, "b": "two", "c": []int{3}},
+	map[int]string{1: "one", 1 << 20: "big"},
+	[][]string{{"x"}, {}, {"y", "z"}},
+	time.Unix(1700000000, 0),
+	-12345,
+	uint64(1) << 63,
+	float32(1.5),
+	"plain string",
+}
+
+// Every input is decoded into each of these
+var dests = []func() interface{}{
+	func() interface{} { return &api.Request{} },
+	func() interface{} { return &api.Response{} },
+	func() interface{} { return &models.OpenAccountReq{} },
+	func() interface{} { return &models.TransferReq{} },
+	func() interface{} { return &models.OpenAccountResp{} },
+	func() interface{} { return &models.QuoteTransferResp{} },
+	func() interface{} { return &models.SessionResp{} },
+	func() interface{} { return &[]models.Account{} },
+	func() interface{} { return &map[string]*int{} },
+	func() interface{} { return &[]interface{}{} },
+	func() interface{} { return &map[string]interface{}{} },
+	func() interface{} { return &map[interface{}]interface{}{} },
+	func() interface{} { return &map[int]string{} },
+	func() interface{} { return &[][]string{} },
+	func() interface{} { return &[2]int{} },
+	func() interface{} { return new(interface{}) },
+	func() interface{} { return new(*time.Time) },
+	func() interface{} { return new(**int) },
+	func() interface{} { return new(uint64) },
+	func() interface{} { return new(float32) },
+	func() interface{} { return new(string) },
+	func() interface{} { return new([]byte) },
+}
+
+// The Decoder may reject any input, but must never panic on one. Inputs found by earlier runs are kept in
+// testdata/fuzz/FuzzDecode.
+func FuzzDecode(f *testing.F) {
+	enc := &codec.Encoder{}
+	for _, s := range seeds {
+		data, err := enc.Marshall(s)
+		if err != nil {
+			f.Fatalf("encoding seed %T: %s", s, err)
+		}
+		f.Add(data)
+	}
+
+	decoders := []*codec.Decoder{{Reflective: true}, {}, {MaxDepth: 4}}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		for _, newDest := range dests {
+			for _, dec := range decoders {
+				dec.Unmarshall(data, newDest())
+			}
+		}
+	})
+}
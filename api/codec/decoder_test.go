@@ -0,0 +1,75 @@
+package codec_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/chiahsoon/cz4013-client/api/codec"
+	"github.com/chiahsoon/cz4013-client/api/models"
+)
+
+func mustEncode(t *testing.T, v interface{}) []byte {
+	t.Helper()
+	data, err := (&codec.Encoder{Reflective: true}).Marshall(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// A string claiming 2^63-1 bytes, followed by only three
+var oversizedString = []byte{byte(reflect.String), 8, 0x7f, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 'a', 'b', 'c'}
+
+func TestDecodeErrors(t *testing.T) {
+	// Shares field names with CloseAccountReq, apart from Extra
+	type closeAccountReqV2 struct {
+		AccountNumber int
+		Name          string
+		Extra         string
+	}
+
+	account := mustEncode(t, models.OpenAccountReq{Name: "Alice Tan", Password: "hunter22", InitialBalance: models.NewMoney(100, "SGD")})
+	cases := []struct {
+		name     string
+		data     []byte
+		dest     func() interface{}
+		maxDepth int
+		want     interface{} // Pointer to the error type expected
+	}{
+		{"truncated string", mustEncode(t, "hello")[:5], func() interface{} { return new(string) }, 0, new(*codec.LengthError)},
+		{"truncated struct", account[:len(account)-1], func() interface{} { return &models.OpenAccountReq{} }, 0, new(*codec.LengthError)},
+		{"oversized string length", oversizedString, func() interface{} { return new(string) }, 0, new(*codec.LengthError)},
+		{"oversized field length", append(mustEncode(t, models.CloseAccountResp{})[:3], oversizedString...),
+			func() interface{} { return &models.CloseAccountResp{} }, 0, new(*codec.LengthError)},
+		{"oversized slice count", []byte{byte(reflect.Slice), 8, 0x7f, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, byte(reflect.Int), 1, 1},
+			func() interface{} { return &[]int{} }, 0, new(*codec.LengthError)},
+		{"too deep", mustEncode(t, [][][][][]int{{{{{1}}}}}), func() interface{} { return &[][][][][]int{} }, 4, new(*codec.DepthError)},
+		{"too deep by default", mustEncode(t, chain(codec.DefaultMaxDepth)), func() interface{} { return &node{} }, 0, new(*codec.DepthError)},
+		{"unknown field", mustEncode(t, closeAccountReqV2{AccountNumber: 1, Name: "Bob", Extra: "x"}),
+			func() interface{} { return &models.CloseAccountReq{} }, 0, new(*codec.UnknownFieldError)},
+	}
+
+	for _, tc := range cases {
+		for _, reflective := range []bool{true, false} {
+			dec := &codec.Decoder{Reflective: reflective, MaxDepth: tc.maxDepth}
+			err := dec.Unmarshall(tc.data, tc.dest())
+			if !errors.As(err, tc.want) {
+				t.Errorf("%s (reflective %t): got %v, want %T", tc.name, reflective, err, tc.want)
+			}
+		}
+	}
+}
+
+// Linked list whose values nest two levels deeper per node, a struct and its pointer field
+type node struct {
+	Next *node
+}
+
+func chain(length int) *node {
+	var head *node
+	for i := 0; i < length; i++ {
+		head = &node{Next: head}
+	}
+	return head
+}
@@ -13,10 +13,16 @@ import (
 
 // Add-on Kinds:
 
-type Encoder struct{}
+type Encoder struct {
+	Reflective bool // Ignore generated Marshaler implementations
+}
 
 func (enc *Encoder) Marshall(data interface{}) ([]byte, error) {
 	rv := reflect.ValueOf(data)
+	if m, ok := data.(Marshaler); ok && !enc.Reflective && !(rv.Kind() == reflect.Ptr && rv.IsNil()) {
+		return m.MarshalCodec()
+	}
+
 	switch rv.Kind() {
 	case reflect.Ptr:
 		return enc.marshallPtr(data)
@@ -26,8 +32,14 @@ func (enc *Encoder) Marshall(data interface{}) ([]byte, error) {
 		}
 		return enc.marshallStruct(data)
 	case reflect.Map:
+		if rv.IsNil() {
+			return enc.marshallNil(), nil
+		}
 		return enc.marshallMap(data)
 	case reflect.Array, reflect.Slice:
+		if rv.Kind() == reflect.Slice && rv.IsNil() {
+			return enc.marshallNil(), nil
+		}
 		return enc.marshallIterable(data)
 	case reflect.String:
 		return enc.marshallString(data)
@@ -41,16 +53,19 @@ func (enc *Encoder) Marshall(data interface{}) ([]byte, error) {
 		reflect.Chan, reflect.Func:
 		return []byte{}, fmt.Errorf("unable to encode kind %d", rv.Kind())
 	case reflect.Invalid:
-		return []byte{}, nil
+		return enc.marshallNil(), nil
 	default:
 		return []byte{}, fmt.Errorf("unknown kind %d", rv.Kind())
 	}
 }
 
 func (enc *Encoder) marshallPtr(data interface{}) ([]byte, error) {
-	// Go to dereferenced value
-	dereferencedRv := reflect.ValueOf(data).Elem()
+	// Go to dereferenced value, any nil pointer along the way is encoded as nil
+	dereferencedRv := reflect.ValueOf(data)
 	for dereferencedRv.Kind() == reflect.Ptr {
+		if dereferencedRv.IsNil() {
+			return enc.marshallNil(), nil
+		}
 		dereferencedRv = dereferencedRv.Elem()
 	}
 
@@ -64,6 +79,11 @@ func (enc *Encoder) marshallPtr(data interface{}) ([]byte, error) {
 	return results, nil
 }
 
+func (enc *Encoder) marshallNil() []byte {
+	// Format: [kind (8-bit)]
+	return []byte{byte(Nil)}
+}
+
 func (enc *Encoder) marshallTime(data interface{}) ([]byte, error) {
 	// Format: [kind (8-bit)][UnixMilli as int]
 	// Cast to time.Time
@@ -147,9 +167,15 @@ func (enc *Encoder) marshallMap(data interface{}) ([]byte, error) {
 
 func (enc *Encoder) marshallStruct(data interface{}) ([]byte, error) {
 	// Format: [kind (8-bit)][#bytes(8-bit) for #fields][#fields][field-value pairs]
+	// Field keys are the field name, or its numeric ID if tagged with one (see struct_fields.go)
 	rv := reflect.ValueOf(data)
 	kindByte := byte(rv.Kind())
-	numFields := rv.NumField()
+	info, err := getStructInfo(rv.Type())
+	if err != nil {
+		return []byte{}, err
+	}
+
+	numFields := len(info.fields)
 	numFieldsBytes, err := enc.packageNum(numFields)
 	if err != nil {
 		return []byte{}, err
@@ -157,18 +183,21 @@ func (enc *Encoder) marshallStruct(data interface{}) ([]byte, error) {
 
 	results := append([]byte{kindByte}, numFieldsBytes...)
 
-	structType := rv.Type()
-	for i := 0; i < numFields; i++ {
-		// Marshall Field Name
-		fieldKey := structType.Field(i)
-		keyBytes, err := enc.Marshall(fieldKey.Name)
+	for _, field := range info.fields {
+		// Marshall Field Key
+		var keyBytes []byte
+		if field.id != 0 {
+			keyBytes, err = enc.marshallNumber(field.id)
+		} else {
+			keyBytes, err = enc.Marshall(field.wireName)
+		}
 		if err != nil {
 			return []byte{}, err
 		}
 
 		// Marshall Field Value
 		var valueBytes []byte
-		fieldValue := rv.Field(i)
+		fieldValue := rv.Field(field.index)
 		if fieldValue.Kind() == reflect.Interface {
 			valueBytes, err = enc.marshallInterface(fieldValue.Interface())
 			if err != nil {
@@ -192,7 +221,12 @@ func (enc *Encoder) marshallInterface(data interface{}) ([]byte, error) {
 	/*
 		- Saves its dynamic data as raw byte slice to be handled by Decoder
 		- Usually only called within maps, structs or iterables
+		- A nil interface has no dynamic data and is encoded as nil
 	*/
+	if data == nil {
+		return enc.marshallNil(), nil
+	}
+
 	kindByte := byte(reflect.Interface)
 	dataBytes, err := enc.Marshall(data)
 	if err != nil {
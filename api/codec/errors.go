@@ -0,0 +1,58 @@
+package codec
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// UnknownFieldError is returned when encoded data names a field the destination struct does not have
+type UnknownFieldError struct {
+	Struct string
+	Name   string // Empty if the field was keyed by ID
+	ID     int
+}
+
+func (e *UnknownFieldError) Error() string {
+	if e.Name == "" {
+		return fmt.Sprintf("unknown field id %d in %s", e.ID, e.Struct)
+	}
+	return fmt.Sprintf("unknown field %q in %s", e.Name, e.Struct)
+}
+
+// UnknownKindError is returned for kind bytes that the Decoder cannot decode
+type UnknownKindError struct {
+	Kind byte
+}
+
+func (e *UnknownKindError) Error() string {
+	return fmt.Sprintf("unknown kind %d", e.Kind)
+}
+
+// KindMismatchError is returned when the encoded kind cannot be stored in the destination type
+type KindMismatchError struct {
+	Kind reflect.Kind
+	Dest reflect.Type
+}
+
+func (e *KindMismatchError) Error() string {
+	return fmt.Sprintf("cannot decode kind %d into %s", e.Kind, e.Dest)
+}
+
+// LengthError is returned when a length or count prefix cannot fit in the remaining data
+type LengthError struct {
+	Length    int64
+	Remaining int
+}
+
+func (e *LengthError) Error() string {
+	return fmt.Sprintf("length %d exceeds the %d bytes remaining", e.Length, e.Remaining)
+}
+
+// DepthError is returned when encoded values are nested deeper than Decoder.MaxDepth
+type DepthError struct {
+	MaxDepth int
+}
+
+func (e *DepthError) Error() string {
+	return fmt.Sprintf("values nested deeper than %d levels", e.MaxDepth)
+}
@@ -0,0 +1,298 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// Marshaler is implemented by types with generated encoding functions (see cmd/codecgen).
+// The output must be byte-identical to what the reflective Encoder produces.
+type Marshaler interface {
+	MarshalCodec() ([]byte, error)
+}
+
+// Unmarshaler is implemented by pointers to types with generated decoding functions
+type Unmarshaler interface {
+	UnmarshalCodec(r *Reader) error
+}
+
+// Helpers used by generated code, mirroring Encoder.packageNum and friends
+
+func AppendStructHeader(b []byte, numFields int) []byte {
+	b = append(b, byte(reflect.Struct))
+	return appendSmallestInt(b, int64(numFields))
+}
+
+func AppendFieldName(b []byte, name string) []byte {
+	return AppendString(b, name)
+}
+
+func AppendFieldID(b []byte, id int) []byte {
+	return AppendInt(b, id)
+}
+
+func AppendString(b []byte, s string) []byte {
+	b = append(b, byte(reflect.String))
+	b = appendSmallestInt(b, int64(len(s)))
+	return append(b, s...)
+}
+
+func AppendInt(b []byte, v int) []byte {
+	return appendSignedNumber(b, reflect.Int, int64(v), strconv.IntSize/8)
+}
+
+func AppendInt8(b []byte, v int8) []byte {
+	return appendSignedNumber(b, reflect.Int8, int64(v), 1)
+}
+
+func AppendInt16(b []byte, v int16) []byte {
+	return appendSignedNumber(b, reflect.Int16, int64(v), 2)
+}
+
+func AppendInt32(b []byte, v int32) []byte {
+	return appendSignedNumber(b, reflect.Int32, int64(v), 4)
+}
+
+func AppendInt64(b []byte, v int64) []byte {
+	return appendSignedNumber(b, reflect.Int64, v, 8)
+}
+
+func AppendUint(b []byte, v uint) []byte {
+	return appendUnsignedNumber(b, reflect.Uint, uint64(v))
+}
+
+func AppendUint8(b []byte, v uint8) []byte {
+	return appendUnsignedNumber(b, reflect.Uint8, uint64(v))
+}
+
+func AppendUint16(b []byte, v uint16) []byte {
+	return appendUnsignedNumber(b, reflect.Uint16, uint64(v))
+}
+
+func AppendUint32(b []byte, v uint32) []byte {
+	return appendUnsignedNumber(b, reflect.Uint32, uint64(v))
+}
+
+func AppendUint64(b []byte, v uint64) []byte {
+	return appendUnsignedNumber(b, reflect.Uint64, v)
+}
+
+func AppendFloat32(b []byte, v float32) []byte {
+	b = append(b, byte(reflect.Float32), 4)
+	return appendBigEndian(b, uint64(math.Float32bits(v)), 4)
+}
+
+func AppendFloat64(b []byte, v float64) []byte {
+	b = append(b, byte(reflect.Float64), 8)
+	return appendBigEndian(b, math.Float64bits(v), 8)
+}
+
+func AppendTime(b []byte, t time.Time) []byte {
+	b = append(b, byte(Time))
+	return AppendInt64(b, t.UnixMilli())
+}
+
+// Falls back to the reflective Encoder for types without a fast path
+func AppendValue(b []byte, v interface{}) ([]byte, error) {
+	enc := Encoder{}
+	encoded, err := enc.Marshall(v)
+	if err != nil {
+		return b, err
+	}
+	return append(b, encoded...), nil
+}
+
+// For fields whose type also has generated methods
+func AppendMarshaler(b []byte, v Marshaler) ([]byte, error) {
+	encoded, err := v.MarshalCodec()
+	if err != nil {
+		return b, err
+	}
+	return append(b, encoded...), nil
+}
+
+func AppendInterface(b []byte, v interface{}) ([]byte, error) {
+	enc := Encoder{}
+	encoded, err := enc.marshallInterface(v)
+	if err != nil {
+		return b, err
+	}
+	return append(b, encoded...), nil
+}
+
+func appendSignedNumber(b []byte, kind reflect.Kind, v int64, size int) []byte {
+	b = append(b, byte(kind))
+	if v >= 0 {
+		return appendSmallestInt(b, v)
+	}
+	b = append(b, byte(size))
+	return appendBigEndian(b, uint64(v), size)
+}
+
+func appendUnsignedNumber(b []byte, kind reflect.Kind, v uint64) []byte {
+	b = append(b, byte(kind))
+	size := 8
+	if v <= math.MaxUint8 {
+		size = 1
+	} else if v <= math.MaxUint16 {
+		size = 2
+	} else if v <= math.MaxUint32 {
+		size = 4
+	}
+	b = append(b, byte(size))
+	return appendBigEndian(b, v, size)
+}
+
+// Same as Encoder.packageNum for non-negative integers
+func appendSmallestInt(b []byte, v int64) []byte {
+	size := 8
+	if v <= math.MaxInt8 {
+		size = 1
+	} else if v <= math.MaxInt16 {
+		size = 2
+	} else if v <= math.MaxInt32 {
+		size = 4
+	}
+	b = append(b, byte(size))
+	return appendBigEndian(b, uint64(v), size)
+}
+
+func appendBigEndian(b []byte, v uint64, size int) []byte {
+	for i := size - 1; i >= 0; i-- {
+		b = append(b, byte(v>>(8*uint(i))))
+	}
+	return b
+}
+
+// Reader is passed to generated UnmarshalCodec functions
+type Reader struct {
+	dec *Decoder
+	buf *bytes.Buffer
+}
+
+func (r *Reader) ReadStructHeader() (int, error) {
+	kind, err := r.buf.ReadByte()
+	if err != nil {
+		return 0, io.ErrUnexpectedEOF
+	}
+	if reflect.Kind(kind) != reflect.Struct {
+		return 0, &KindMismatchError{Kind: reflect.Kind(kind), Dest: reflect.TypeOf(struct{}{})}
+	}
+
+	numFields, err := r.readBoundedLength()
+	return int(numFields), err
+}
+
+// Returns either the name or the numeric ID of the next field
+func (r *Reader) ReadFieldKey() (string, int, error) {
+	if r.buf.Len() == 0 {
+		return "", 0, io.ErrUnexpectedEOF
+	}
+
+	if reflect.Kind(r.buf.Bytes()[0]) == reflect.String {
+		name, err := r.ReadString()
+		return name, 0, err
+	}
+
+	id, err := r.ReadInt()
+	return "", int(id), err
+}
+
+func (r *Reader) ReadString() (string, error) {
+	kind, err := r.buf.ReadByte()
+	if err != nil {
+		return "", io.ErrUnexpectedEOF
+	}
+	if reflect.Kind(kind) != reflect.String {
+		return "", &KindMismatchError{Kind: reflect.Kind(kind), Dest: reflect.TypeOf("")}
+	}
+
+	length, err := r.readBoundedLength()
+	if err != nil {
+		return "", err
+	}
+	return string(r.buf.Next(int(length))), nil
+}
+
+// Reads any integer kind, sign-extending like the reflective Decoder does
+func (r *Reader) ReadInt() (int64, error) {
+	kind, err := r.buf.ReadByte()
+	if err != nil {
+		return 0, io.ErrUnexpectedEOF
+	}
+	switch reflect.Kind(kind) {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+	default:
+		return 0, &KindMismatchError{Kind: reflect.Kind(kind), Dest: reflect.TypeOf(int64(0))}
+	}
+
+	data, err := r.dec.readNumberBytes(r.buf)
+	if err != nil {
+		return 0, err
+	}
+
+	var v int64
+	if data[0] >= 128 {
+		v = -1
+	}
+	for _, b := range data {
+		v = v<<8 | int64(b)
+	}
+	return v, nil
+}
+
+func (r *Reader) ReadFloat64() (float64, error) {
+	kind, err := r.buf.ReadByte()
+	if err != nil {
+		return 0, io.ErrUnexpectedEOF
+	}
+
+	data, err := r.dec.readNumberBytes(r.buf)
+	if err != nil {
+		return 0, err
+	}
+
+	switch {
+	case reflect.Kind(kind) == reflect.Float64 && len(data) == 8:
+		return math.Float64frombits(binary.BigEndian.Uint64(data)), nil
+	case reflect.Kind(kind) == reflect.Float32 && len(data) == 4:
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(data))), nil
+	case reflect.Kind(kind) == reflect.Float32 || reflect.Kind(kind) == reflect.Float64:
+		return 0, fmt.Errorf("invalid float size %d", len(data))
+	default:
+		return 0, &KindMismatchError{Kind: reflect.Kind(kind), Dest: reflect.TypeOf(float64(0))}
+	}
+}
+
+// Falls back to the reflective Decoder, dest must be a pointer
+func (r *Reader) ReadValue(dest interface{}) error {
+	return r.dec.unmarshall(r.buf, dest)
+}
+
+func (r *Reader) UnknownField(structName, name string, id int) error {
+	return &UnknownFieldError{Struct: structName, Name: name, ID: id}
+}
+
+// Same format as Decoder.readBoundedLength without going through reflection
+func (r *Reader) readBoundedLength() (int64, error) {
+	data, err := r.dec.readNumberBytes(r.buf)
+	if err != nil {
+		return 0, err
+	}
+
+	var length int64
+	for _, b := range data {
+		length = length<<8 | int64(b)
+	}
+	if length < 0 || length > int64(r.buf.Len()) {
+		return 0, &LengthError{Length: length, Remaining: r.buf.Len()}
+	}
+	return length, nil
+}
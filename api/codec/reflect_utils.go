@@ -7,6 +7,9 @@ import (
 
 const Time reflect.Kind = 27
 
+// Nil marks a nil pointer, slice, map or interface, which is decoded as nil without allocating
+const Nil reflect.Kind = 28
+
 var kindToType = map[reflect.Kind]reflect.Type{
 	reflect.Bool:          reflect.TypeOf(false),
 	reflect.Int:           reflect.TypeOf(int(0)),
@@ -0,0 +1,112 @@
+package codec
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const TagName = "codec"
+
+/*
+	Struct tags map fields to compact numeric IDs on the wire:
+		Field int `codec:"1"`              - encoded with key 1 instead of "Field"
+		Field int `codec:"1,name=acct"`    - key 1, and "acct" is also accepted when decoding
+		Field int `codec:",name=acct"`     - encoded with key "acct"
+		Field int `codec:"-"`              - never encoded
+	Untagged fields keep using their Go name as the key, so untagged structs are unchanged on the wire.
+*/
+
+type fieldInfo struct {
+	index    int    // Index of the Go field
+	wireName string // Key when id is 0
+	id       int    // Numeric key, 0 if not tagged with one
+}
+
+type structInfo struct {
+	fields []fieldInfo // Encoded fields, in declaration order
+	byName map[string]int
+	byID   map[int]int
+}
+
+var structInfoCache sync.Map // reflect.Type -> *structInfo
+
+func getStructInfo(t reflect.Type) (*structInfo, error) {
+	if cached, ok := structInfoCache.Load(t); ok {
+		return cached.(*structInfo), nil
+	}
+
+	info := &structInfo{byName: map[string]int{}, byID: map[int]int{}}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue // Unexported
+		}
+
+		id, name, skip, err := ParseTag(field.Tag.Get(TagName))
+		if err != nil {
+			return nil, fmt.Errorf("invalid codec tag on %s.%s: %s", t, field.Name, err)
+		}
+		if skip {
+			continue
+		}
+
+		fi := fieldInfo{index: i, wireName: field.Name, id: id}
+		aliases := []string{field.Name}
+		if name != "" {
+			fi.wireName = name
+			aliases = append(aliases, name)
+		}
+
+		pos := len(info.fields)
+		if fi.id != 0 {
+			if _, ok := info.byID[fi.id]; ok {
+				return nil, fmt.Errorf("duplicate codec id %d in %s", fi.id, t)
+			}
+			info.byID[fi.id] = pos
+		}
+		for _, alias := range aliases {
+			if other, ok := info.byName[alias]; ok && other != pos {
+				return nil, fmt.Errorf("duplicate codec name %q in %s", alias, t)
+			}
+			info.byName[alias] = pos
+		}
+		info.fields = append(info.fields, fi)
+	}
+
+	structInfoCache.Store(t, info)
+	return info, nil
+}
+
+// ParseTag returns the numeric ID and wire name of a field tag, or skip if the field is not encoded
+func ParseTag(tag string) (id int, name string, skip bool, err error) {
+	if tag == "" {
+		return 0, "", false, nil
+	}
+	if tag == "-" {
+		return 0, "", true, nil
+	}
+
+	parts := strings.Split(tag, ",")
+	if parts[0] != "" {
+		parsed, err := strconv.Atoi(parts[0])
+		if err != nil || parsed <= 0 {
+			return 0, "", false, fmt.Errorf("id %q is not a positive integer", parts[0])
+		}
+		id = parsed
+	}
+
+	for _, opt := range parts[1:] {
+		if !strings.HasPrefix(opt, "name=") || len(opt) == len("name=") {
+			return 0, "", false, fmt.Errorf("unknown option %q", opt)
+		}
+		name = strings.TrimPrefix(opt, "name=")
+	}
+
+	if id == 0 && name == "" {
+		return 0, "", false, fmt.Errorf("tag %q has neither an id nor a name", tag)
+	}
+	return id, name, false, nil
+}
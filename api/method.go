@@ -12,11 +12,16 @@ const (
 	MonitorAPI       APIMethod = "monitor"
 	CheckStateAPI    APIMethod = "check_state"
 	TransferAPI      APIMethod = "transfer"
+	QuoteTransferAPI APIMethod = "quote_transfer"
+	LoginAPI         APIMethod = "login"
+	RefreshAPI       APIMethod = "refresh"
+	LogoutAPI        APIMethod = "logout"
 )
 
 func (m APIMethod) Validate() error {
 	switch m {
-	case OpenAccountAPI, CloseAccountAPI, GetBalanceAPI, UpdateBalanceAPI, MonitorAPI, CheckStateAPI, TransferAPI:
+	case OpenAccountAPI, CloseAccountAPI, GetBalanceAPI, UpdateBalanceAPI, MonitorAPI, CheckStateAPI, TransferAPI, QuoteTransferAPI,
+		LoginAPI, RefreshAPI, LogoutAPI:
 		return nil
 	}
 	return errors.New("invalid api method")
@@ -3,18 +3,17 @@ package models
 import "fmt"
 
 type Account struct {
-	Number     int
-	HolderName string
-	Password   string
-	Currency   Currency
-	Balance    float64
+	Number     int    `codec:"1"`
+	HolderName string `codec:"2"`
+	Password   string `codec:"3"`
+	Balance    Money  `codec:"4"` // Denominated in the account currency
 }
 
 func (acc *Account) GetDetails() string {
 	details := ""
 	details += fmt.Sprintf("Account Number: %d\n", acc.Number)
 	details += fmt.Sprintf("Account Holder Name: %s\n", acc.HolderName)
-	details += fmt.Sprintf("Account Currency: %s\n", acc.Currency)
-	details += fmt.Sprintf("Account Balance: %f\n", acc.Balance)
+	details += fmt.Sprintf("Account Currency: %s\n", acc.Balance.Currency)
+	details += fmt.Sprintf("Account Balance: %s\n", acc.Balance.Decimal())
 	return details
 }
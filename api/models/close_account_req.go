@@ -1,7 +1,7 @@
 package models
 
 type CloseAccountReq struct {
-	AccountNumber int
-	Name          string
-	Password      string
+	AccountNumber int    `codec:"1"`
+	Name          string `codec:"2"`
+	Password      string `codec:"3"`
 }
@@ -1,5 +1,5 @@
 package models
 
 type CloseAccountResp struct {
-	Message string
+	Message string `codec:"1"`
 }
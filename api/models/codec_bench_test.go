@@ -0,0 +1,51 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/chiahsoon/cz4013-client/api/codec"
+)
+
+// Paths compared by the benchmarks, see TestGeneratedCodecMatchesReflective for their output agreeing
+var paths = []struct {
+	name string
+	enc  *codec.Encoder
+	dec  *codec.Decoder
+}{
+	{"reflective", &codec.Encoder{Reflective: true}, &codec.Decoder{Reflective: true}},
+	{"generated", &codec.Encoder{}, &codec.Decoder{}},
+}
+
+func BenchmarkEncode(b *testing.B) {
+	for _, s := range samples {
+		for _, p := range paths {
+			b.Run(s.name+"/"+p.name, func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					if _, err := p.enc.Marshall(s.value); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkDecode(b *testing.B) {
+	for _, s := range samples {
+		data, err := paths[0].enc.Marshall(s.value)
+		if err != nil {
+			b.Fatal(err)
+		}
+		for _, p := range paths {
+			b.Run(s.name+"/"+p.name, func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					if err := p.dec.Unmarshall(data, s.new()); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
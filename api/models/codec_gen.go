@@ -0,0 +1,782 @@
+// Code generated by codecgen. DO NOT EDIT.
+
+package models
+
+import "github.com/chiahsoon/cz4013-client/api/codec"
+
+func (m Account) MarshalCodec() ([]byte, error) {
+	b := make([]byte, 0, 64)
+	var err error
+	b = codec.AppendStructHeader(b, 4)
+	b = codec.AppendFieldID(b, 1)
+	b = codec.AppendInt(b, int(m.Number))
+	b = codec.AppendFieldID(b, 2)
+	b = codec.AppendString(b, string(m.HolderName))
+	b = codec.AppendFieldID(b, 3)
+	b = codec.AppendString(b, string(m.Password))
+	b = codec.AppendFieldID(b, 4)
+	if b, err = codec.AppendMarshaler(b, m.Balance); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (m *Account) UnmarshalCodec(r *codec.Reader) error {
+	numFields, err := r.ReadStructHeader()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < numFields; i++ {
+		name, id, err := r.ReadFieldKey()
+		if err != nil {
+			return err
+		}
+		switch {
+		case name == "Number" || id == 1:
+			v, err := r.ReadInt()
+			if err != nil {
+				return err
+			}
+			m.Number = int(v)
+		case name == "HolderName" || id == 2:
+			v, err := r.ReadString()
+			if err != nil {
+				return err
+			}
+			m.HolderName = string(v)
+		case name == "Password" || id == 3:
+			v, err := r.ReadString()
+			if err != nil {
+				return err
+			}
+			m.Password = string(v)
+		case name == "Balance" || id == 4:
+			if err := r.ReadValue(&m.Balance); err != nil {
+				return err
+			}
+		default:
+			return r.UnknownField("Account", name, id)
+		}
+	}
+	return nil
+}
+
+func (m CloseAccountReq) MarshalCodec() ([]byte, error) {
+	b := make([]byte, 0, 64)
+	b = codec.AppendStructHeader(b, 3)
+	b = codec.AppendFieldID(b, 1)
+	b = codec.AppendInt(b, int(m.AccountNumber))
+	b = codec.AppendFieldID(b, 2)
+	b = codec.AppendString(b, string(m.Name))
+	b = codec.AppendFieldID(b, 3)
+	b = codec.AppendString(b, string(m.Password))
+	return b, nil
+}
+
+func (m *CloseAccountReq) UnmarshalCodec(r *codec.Reader) error {
+	numFields, err := r.ReadStructHeader()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < numFields; i++ {
+		name, id, err := r.ReadFieldKey()
+		if err != nil {
+			return err
+		}
+		switch {
+		case name == "AccountNumber" || id == 1:
+			v, err := r.ReadInt()
+			if err != nil {
+				return err
+			}
+			m.AccountNumber = int(v)
+		case name == "Name" || id == 2:
+			v, err := r.ReadString()
+			if err != nil {
+				return err
+			}
+			m.Name = string(v)
+		case name == "Password" || id == 3:
+			v, err := r.ReadString()
+			if err != nil {
+				return err
+			}
+			m.Password = string(v)
+		default:
+			return r.UnknownField("CloseAccountReq", name, id)
+		}
+	}
+	return nil
+}
+
+func (m CloseAccountResp) MarshalCodec() ([]byte, error) {
+	b := make([]byte, 0, 64)
+	b = codec.AppendStructHeader(b, 1)
+	b = codec.AppendFieldID(b, 1)
+	b = codec.AppendString(b, string(m.Message))
+	return b, nil
+}
+
+func (m *CloseAccountResp) UnmarshalCodec(r *codec.Reader) error {
+	numFields, err := r.ReadStructHeader()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < numFields; i++ {
+		name, id, err := r.ReadFieldKey()
+		if err != nil {
+			return err
+		}
+		switch {
+		case name == "Message" || id == 1:
+			v, err := r.ReadString()
+			if err != nil {
+				return err
+			}
+			m.Message = string(v)
+		default:
+			return r.UnknownField("CloseAccountResp", name, id)
+		}
+	}
+	return nil
+}
+
+func (m GetBalanceReq) MarshalCodec() ([]byte, error) {
+	b := make([]byte, 0, 64)
+	b = codec.AppendStructHeader(b, 4)
+	b = codec.AppendFieldID(b, 1)
+	b = codec.AppendInt(b, int(m.AccountNumber))
+	b = codec.AppendFieldID(b, 2)
+	b = codec.AppendString(b, string(m.Name))
+	b = codec.AppendFieldID(b, 3)
+	b = codec.AppendString(b, string(m.Password))
+	b = codec.AppendFieldID(b, 4)
+	b = codec.AppendString(b, string(m.Currency))
+	return b, nil
+}
+
+func (m *GetBalanceReq) UnmarshalCodec(r *codec.Reader) error {
+	numFields, err := r.ReadStructHeader()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < numFields; i++ {
+		name, id, err := r.ReadFieldKey()
+		if err != nil {
+			return err
+		}
+		switch {
+		case name == "AccountNumber" || id == 1:
+			v, err := r.ReadInt()
+			if err != nil {
+				return err
+			}
+			m.AccountNumber = int(v)
+		case name == "Name" || id == 2:
+			v, err := r.ReadString()
+			if err != nil {
+				return err
+			}
+			m.Name = string(v)
+		case name == "Password" || id == 3:
+			v, err := r.ReadString()
+			if err != nil {
+				return err
+			}
+			m.Password = string(v)
+		case name == "Currency" || id == 4:
+			v, err := r.ReadString()
+			if err != nil {
+				return err
+			}
+			m.Currency = Currency(v)
+		default:
+			return r.UnknownField("GetBalanceReq", name, id)
+		}
+	}
+	return nil
+}
+
+func (m GetBalanceResp) MarshalCodec() ([]byte, error) {
+	b := make([]byte, 0, 64)
+	var err error
+	b = codec.AppendStructHeader(b, 1)
+	b = codec.AppendFieldID(b, 1)
+	if b, err = codec.AppendMarshaler(b, m.Balance); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (m *GetBalanceResp) UnmarshalCodec(r *codec.Reader) error {
+	numFields, err := r.ReadStructHeader()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < numFields; i++ {
+		name, id, err := r.ReadFieldKey()
+		if err != nil {
+			return err
+		}
+		switch {
+		case name == "Balance" || id == 1:
+			if err := r.ReadValue(&m.Balance); err != nil {
+				return err
+			}
+		default:
+			return r.UnknownField("GetBalanceResp", name, id)
+		}
+	}
+	return nil
+}
+
+func (m LoginReq) MarshalCodec() ([]byte, error) {
+	b := make([]byte, 0, 64)
+	b = codec.AppendStructHeader(b, 3)
+	b = codec.AppendFieldID(b, 1)
+	b = codec.AppendInt(b, int(m.AccountNumber))
+	b = codec.AppendFieldID(b, 2)
+	b = codec.AppendString(b, string(m.Name))
+	b = codec.AppendFieldID(b, 3)
+	b = codec.AppendString(b, string(m.Password))
+	return b, nil
+}
+
+func (m *LoginReq) UnmarshalCodec(r *codec.Reader) error {
+	numFields, err := r.ReadStructHeader()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < numFields; i++ {
+		name, id, err := r.ReadFieldKey()
+		if err != nil {
+			return err
+		}
+		switch {
+		case name == "AccountNumber" || id == 1:
+			v, err := r.ReadInt()
+			if err != nil {
+				return err
+			}
+			m.AccountNumber = int(v)
+		case name == "Name" || id == 2:
+			v, err := r.ReadString()
+			if err != nil {
+				return err
+			}
+			m.Name = string(v)
+		case name == "Password" || id == 3:
+			v, err := r.ReadString()
+			if err != nil {
+				return err
+			}
+			m.Password = string(v)
+		default:
+			return r.UnknownField("LoginReq", name, id)
+		}
+	}
+	return nil
+}
+
+func (m Money) MarshalCodec() ([]byte, error) {
+	b := make([]byte, 0, 64)
+	b = codec.AppendStructHeader(b, 2)
+	b = codec.AppendFieldID(b, 1)
+	b = codec.AppendInt64(b, int64(m.Units))
+	b = codec.AppendFieldID(b, 2)
+	b = codec.AppendString(b, string(m.Currency))
+	return b, nil
+}
+
+func (m *Money) UnmarshalCodec(r *codec.Reader) error {
+	numFields, err := r.ReadStructHeader()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < numFields; i++ {
+		name, id, err := r.ReadFieldKey()
+		if err != nil {
+			return err
+		}
+		switch {
+		case name == "Units" || id == 1:
+			v, err := r.ReadInt()
+			if err != nil {
+				return err
+			}
+			m.Units = int64(v)
+		case name == "Currency" || id == 2:
+			v, err := r.ReadString()
+			if err != nil {
+				return err
+			}
+			m.Currency = Currency(v)
+		default:
+			return r.UnknownField("Money", name, id)
+		}
+	}
+	return nil
+}
+
+func (m MonitorReq) MarshalCodec() ([]byte, error) {
+	b := make([]byte, 0, 64)
+	b = codec.AppendStructHeader(b, 1)
+	b = codec.AppendFieldID(b, 1)
+	b = codec.AppendInt(b, int(m.Interval))
+	return b, nil
+}
+
+func (m *MonitorReq) UnmarshalCodec(r *codec.Reader) error {
+	numFields, err := r.ReadStructHeader()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < numFields; i++ {
+		name, id, err := r.ReadFieldKey()
+		if err != nil {
+			return err
+		}
+		switch {
+		case name == "Interval" || id == 1:
+			v, err := r.ReadInt()
+			if err != nil {
+				return err
+			}
+			m.Interval = int(v)
+		default:
+			return r.UnknownField("MonitorReq", name, id)
+		}
+	}
+	return nil
+}
+
+func (m OpenAccountReq) MarshalCodec() ([]byte, error) {
+	b := make([]byte, 0, 64)
+	var err error
+	b = codec.AppendStructHeader(b, 4)
+	b = codec.AppendFieldID(b, 1)
+	b = codec.AppendInt(b, int(m.AccountNumber))
+	b = codec.AppendFieldID(b, 2)
+	b = codec.AppendString(b, string(m.Name))
+	b = codec.AppendFieldID(b, 3)
+	b = codec.AppendString(b, string(m.Password))
+	b = codec.AppendFieldID(b, 4)
+	if b, err = codec.AppendMarshaler(b, m.InitialBalance); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (m *OpenAccountReq) UnmarshalCodec(r *codec.Reader) error {
+	numFields, err := r.ReadStructHeader()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < numFields; i++ {
+		name, id, err := r.ReadFieldKey()
+		if err != nil {
+			return err
+		}
+		switch {
+		case name == "AccountNumber" || id == 1:
+			v, err := r.ReadInt()
+			if err != nil {
+				return err
+			}
+			m.AccountNumber = int(v)
+		case name == "Name" || id == 2:
+			v, err := r.ReadString()
+			if err != nil {
+				return err
+			}
+			m.Name = string(v)
+		case name == "Password" || id == 3:
+			v, err := r.ReadString()
+			if err != nil {
+				return err
+			}
+			m.Password = string(v)
+		case name == "InitialBalance" || id == 4:
+			if err := r.ReadValue(&m.InitialBalance); err != nil {
+				return err
+			}
+		default:
+			return r.UnknownField("OpenAccountReq", name, id)
+		}
+	}
+	return nil
+}
+
+func (m OpenAccountResp) MarshalCodec() ([]byte, error) {
+	b := make([]byte, 0, 64)
+	b = codec.AppendStructHeader(b, 1)
+	b = codec.AppendFieldID(b, 1)
+	b = codec.AppendString(b, string(m.Message))
+	return b, nil
+}
+
+func (m *OpenAccountResp) UnmarshalCodec(r *codec.Reader) error {
+	numFields, err := r.ReadStructHeader()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < numFields; i++ {
+		name, id, err := r.ReadFieldKey()
+		if err != nil {
+			return err
+		}
+		switch {
+		case name == "Message" || id == 1:
+			v, err := r.ReadString()
+			if err != nil {
+				return err
+			}
+			m.Message = string(v)
+		default:
+			return r.UnknownField("OpenAccountResp", name, id)
+		}
+	}
+	return nil
+}
+
+func (m QuoteTransferReq) MarshalCodec() ([]byte, error) {
+	b := make([]byte, 0, 64)
+	var err error
+	b = codec.AppendStructHeader(b, 5)
+	b = codec.AppendFieldID(b, 1)
+	b = codec.AppendInt(b, int(m.AccountNumber))
+	b = codec.AppendFieldID(b, 2)
+	b = codec.AppendString(b, string(m.Name))
+	b = codec.AppendFieldID(b, 3)
+	b = codec.AppendString(b, string(m.Password))
+	b = codec.AppendFieldID(b, 4)
+	if b, err = codec.AppendMarshaler(b, m.Amount); err != nil {
+		return nil, err
+	}
+	b = codec.AppendFieldID(b, 5)
+	b = codec.AppendInt(b, int(m.DestAccountNumber))
+	return b, nil
+}
+
+func (m *QuoteTransferReq) UnmarshalCodec(r *codec.Reader) error {
+	numFields, err := r.ReadStructHeader()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < numFields; i++ {
+		name, id, err := r.ReadFieldKey()
+		if err != nil {
+			return err
+		}
+		switch {
+		case name == "AccountNumber" || id == 1:
+			v, err := r.ReadInt()
+			if err != nil {
+				return err
+			}
+			m.AccountNumber = int(v)
+		case name == "Name" || id == 2:
+			v, err := r.ReadString()
+			if err != nil {
+				return err
+			}
+			m.Name = string(v)
+		case name == "Password" || id == 3:
+			v, err := r.ReadString()
+			if err != nil {
+				return err
+			}
+			m.Password = string(v)
+		case name == "Amount" || id == 4:
+			if err := r.ReadValue(&m.Amount); err != nil {
+				return err
+			}
+		case name == "DestAccountNumber" || id == 5:
+			v, err := r.ReadInt()
+			if err != nil {
+				return err
+			}
+			m.DestAccountNumber = int(v)
+		default:
+			return r.UnknownField("QuoteTransferReq", name, id)
+		}
+	}
+	return nil
+}
+
+func (m QuoteTransferResp) MarshalCodec() ([]byte, error) {
+	b := make([]byte, 0, 64)
+	var err error
+	b = codec.AppendStructHeader(b, 2)
+	b = codec.AppendFieldID(b, 1)
+	if b, err = codec.AppendMarshaler(b, m.Received); err != nil {
+		return nil, err
+	}
+	b = codec.AppendFieldID(b, 2)
+	b = codec.AppendString(b, string(m.Rate))
+	return b, nil
+}
+
+func (m *QuoteTransferResp) UnmarshalCodec(r *codec.Reader) error {
+	numFields, err := r.ReadStructHeader()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < numFields; i++ {
+		name, id, err := r.ReadFieldKey()
+		if err != nil {
+			return err
+		}
+		switch {
+		case name == "Received" || id == 1:
+			if err := r.ReadValue(&m.Received); err != nil {
+				return err
+			}
+		case name == "Rate" || id == 2:
+			v, err := r.ReadString()
+			if err != nil {
+				return err
+			}
+			m.Rate = string(v)
+		default:
+			return r.UnknownField("QuoteTransferResp", name, id)
+		}
+	}
+	return nil
+}
+
+func (m SessionResp) MarshalCodec() ([]byte, error) {
+	b := make([]byte, 0, 64)
+	b = codec.AppendStructHeader(b, 2)
+	b = codec.AppendFieldID(b, 1)
+	b = codec.AppendString(b, string(m.Token))
+	b = codec.AppendFieldID(b, 2)
+	b = codec.AppendInt(b, int(m.ExpiresIn))
+	return b, nil
+}
+
+func (m *SessionResp) UnmarshalCodec(r *codec.Reader) error {
+	numFields, err := r.ReadStructHeader()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < numFields; i++ {
+		name, id, err := r.ReadFieldKey()
+		if err != nil {
+			return err
+		}
+		switch {
+		case name == "Token" || id == 1:
+			v, err := r.ReadString()
+			if err != nil {
+				return err
+			}
+			m.Token = string(v)
+		case name == "ExpiresIn" || id == 2:
+			v, err := r.ReadInt()
+			if err != nil {
+				return err
+			}
+			m.ExpiresIn = int(v)
+		default:
+			return r.UnknownField("SessionResp", name, id)
+		}
+	}
+	return nil
+}
+
+func (m TransferReq) MarshalCodec() ([]byte, error) {
+	b := make([]byte, 0, 64)
+	var err error
+	b = codec.AppendStructHeader(b, 6)
+	b = codec.AppendFieldID(b, 1)
+	b = codec.AppendInt(b, int(m.AccountNumber))
+	b = codec.AppendFieldID(b, 2)
+	b = codec.AppendString(b, string(m.Name))
+	b = codec.AppendFieldID(b, 3)
+	b = codec.AppendString(b, string(m.Password))
+	b = codec.AppendFieldID(b, 4)
+	if b, err = codec.AppendMarshaler(b, m.Amount); err != nil {
+		return nil, err
+	}
+	b = codec.AppendFieldID(b, 5)
+	b = codec.AppendInt(b, int(m.DestAccountNumber))
+	b = codec.AppendFieldID(b, 6)
+	if b, err = codec.AppendValue(b, m.Memo); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (m *TransferReq) UnmarshalCodec(r *codec.Reader) error {
+	numFields, err := r.ReadStructHeader()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < numFields; i++ {
+		name, id, err := r.ReadFieldKey()
+		if err != nil {
+			return err
+		}
+		switch {
+		case name == "AccountNumber" || id == 1:
+			v, err := r.ReadInt()
+			if err != nil {
+				return err
+			}
+			m.AccountNumber = int(v)
+		case name == "Name" || id == 2:
+			v, err := r.ReadString()
+			if err != nil {
+				return err
+			}
+			m.Name = string(v)
+		case name == "Password" || id == 3:
+			v, err := r.ReadString()
+			if err != nil {
+				return err
+			}
+			m.Password = string(v)
+		case name == "Amount" || id == 4:
+			if err := r.ReadValue(&m.Amount); err != nil {
+				return err
+			}
+		case name == "DestAccountNumber" || id == 5:
+			v, err := r.ReadInt()
+			if err != nil {
+				return err
+			}
+			m.DestAccountNumber = int(v)
+		case name == "Memo" || id == 6:
+			if err := r.ReadValue(&m.Memo); err != nil {
+				return err
+			}
+		default:
+			return r.UnknownField("TransferReq", name, id)
+		}
+	}
+	return nil
+}
+
+func (m TransferResp) MarshalCodec() ([]byte, error) {
+	b := make([]byte, 0, 64)
+	var err error
+	b = codec.AppendStructHeader(b, 1)
+	b = codec.AppendFieldID(b, 1)
+	if b, err = codec.AppendMarshaler(b, m.Balance); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (m *TransferResp) UnmarshalCodec(r *codec.Reader) error {
+	numFields, err := r.ReadStructHeader()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < numFields; i++ {
+		name, id, err := r.ReadFieldKey()
+		if err != nil {
+			return err
+		}
+		switch {
+		case name == "Balance" || id == 1:
+			if err := r.ReadValue(&m.Balance); err != nil {
+				return err
+			}
+		default:
+			return r.UnknownField("TransferResp", name, id)
+		}
+	}
+	return nil
+}
+
+func (m UpdateBalanceReq) MarshalCodec() ([]byte, error) {
+	b := make([]byte, 0, 64)
+	var err error
+	b = codec.AppendStructHeader(b, 4)
+	b = codec.AppendFieldID(b, 1)
+	b = codec.AppendInt(b, int(m.AccountNumber))
+	b = codec.AppendFieldID(b, 2)
+	b = codec.AppendString(b, string(m.Name))
+	b = codec.AppendFieldID(b, 3)
+	b = codec.AppendString(b, string(m.Password))
+	b = codec.AppendFieldID(b, 4)
+	if b, err = codec.AppendMarshaler(b, m.Amount); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (m *UpdateBalanceReq) UnmarshalCodec(r *codec.Reader) error {
+	numFields, err := r.ReadStructHeader()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < numFields; i++ {
+		name, id, err := r.ReadFieldKey()
+		if err != nil {
+			return err
+		}
+		switch {
+		case name == "AccountNumber" || id == 1:
+			v, err := r.ReadInt()
+			if err != nil {
+				return err
+			}
+			m.AccountNumber = int(v)
+		case name == "Name" || id == 2:
+			v, err := r.ReadString()
+			if err != nil {
+				return err
+			}
+			m.Name = string(v)
+		case name == "Password" || id == 3:
+			v, err := r.ReadString()
+			if err != nil {
+				return err
+			}
+			m.Password = string(v)
+		case name == "Amount" || id == 4:
+			if err := r.ReadValue(&m.Amount); err != nil {
+				return err
+			}
+		default:
+			return r.UnknownField("UpdateBalanceReq", name, id)
+		}
+	}
+	return nil
+}
+
+func (m UpdateBalanceResp) MarshalCodec() ([]byte, error) {
+	b := make([]byte, 0, 64)
+	var err error
+	b = codec.AppendStructHeader(b, 1)
+	b = codec.AppendFieldID(b, 1)
+	if b, err = codec.AppendMarshaler(b, m.Balance); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (m *UpdateBalanceResp) UnmarshalCodec(r *codec.Reader) error {
+	numFields, err := r.ReadStructHeader()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < numFields; i++ {
+		name, id, err := r.ReadFieldKey()
+		if err != nil {
+			return err
+		}
+		switch {
+		case name == "Balance" || id == 1:
+			if err := r.ReadValue(&m.Balance); err != nil {
+				return err
+			}
+		default:
+			return r.UnknownField("UpdateBalanceResp", name, id)
+		}
+	}
+	return nil
+}
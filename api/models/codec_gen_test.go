@@ -0,0 +1,190 @@
+package models
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"testing"
+
+	"github.com/chiahsoon/cz4013-client/api/codec"
+)
+
+type sample struct {
+	name  string
+	value interface{}
+	new   func() interface{}
+}
+
+var memo = "rent"
+
+// One or more values of every type in codec_gen.go
+var samples = []sample{
+	{"Account", Account{Number: 70000, HolderName: "Bob Lim", Password: "hunter22", Balance: NewMoney(-350, "USD")},
+		func() interface{} { return &Account{} }},
+	{"CloseAccountReq", CloseAccountReq{AccountNumber: 42, Name: "Alice Tan", Password: "hunter22"},
+		func() interface{} { return &CloseAccountReq{} }},
+	{"CloseAccountResp", CloseAccountResp{Message: "Account 42 closed"},
+		func() interface{} { return &CloseAccountResp{} }},
+	{"GetBalanceReq", GetBalanceReq{AccountNumber: 42, Name: "Alice Tan", Password: "hunter22", Currency: "JPY"},
+		func() interface{} { return &GetBalanceReq{} }},
+	{"GetBalanceResp", GetBalanceResp{Balance: NewMoney(150025, "SGD")},
+		func() interface{} { return &GetBalanceResp{} }},
+	{"LoginReq", LoginReq{AccountNumber: 42, Name: "Alice Tan", Password: "hunter22"},
+		func() interface{} { return &LoginReq{} }},
+	{"Money", NewMoney(1<<40, "IDR"),
+		func() interface{} { return &Money{} }},
+	{"MonitorReq", MonitorReq{Interval: 300},
+		func() interface{} { return &MonitorReq{} }},
+	{"OpenAccountReq", OpenAccountReq{Name: "Alice Tan", Password: "hunter22", InitialBalance: NewMoney(150025, "SGD")},
+		func() interface{} { return &OpenAccountReq{} }},
+	{"OpenAccountResp", OpenAccountResp{Message: "Account opened, account number 42"},
+		func() interface{} { return &OpenAccountResp{} }},
+	{"QuoteTransferReq", QuoteTransferReq{AccountNumber: 42, Name: "Alice Tan", Password: "hunter22", Amount: NewMoney(9999, "SGD"), DestAccountNumber: 4242},
+		func() interface{} { return &QuoteTransferReq{} }},
+	{"QuoteTransferResp", QuoteTransferResp{Received: NewMoney(7399, "USD"), Rate: "0.74"},
+		func() interface{} { return &QuoteTransferResp{} }},
+	{"SessionResp", SessionResp{Token: "00112233445566778899aabbccddeeff", ExpiresIn: 300},
+		func() interface{} { return &SessionResp{} }},
+	{"TransferReq", TransferReq{AccountNumber: 42, DestAccountNumber: 4242, Name: "Alice Tan", Password: "hunter22", Amount: NewMoney(9999, "SGD")},
+		func() interface{} { return &TransferReq{} }},
+	{"TransferReqMemo", TransferReq{AccountNumber: 42, DestAccountNumber: 4242, Name: "Alice Tan", Password: "hunter22", Amount: NewMoney(500, "SGD"), Memo: &memo},
+		func() interface{} { return &TransferReq{} }},
+	{"TransferResp", TransferResp{Balance: NewMoney(-1, "EUR")},
+		func() interface{} { return &TransferResp{} }},
+	{"UpdateBalanceReq", UpdateBalanceReq{AccountNumber: 42, Name: "Alice Tan", Password: "hunter22", Amount: NewMoney(-2050, "SGD")},
+		func() interface{} { return &UpdateBalanceReq{} }},
+	{"UpdateBalanceResp", UpdateBalanceResp{Balance: NewMoney(0, "SGD")},
+		func() interface{} { return &UpdateBalanceResp{} }},
+}
+
+// Generated methods are only used when their output is byte-identical to the reflective Encoder's, so a stale
+// codec_gen.go must fail here rather than on the wire
+func TestGeneratedCodecMatchesReflective(t *testing.T) {
+	reflective := &codec.Encoder{Reflective: true}
+	generated := &codec.Encoder{}
+	decoders := map[string]*codec.Decoder{
+		"reflective": {Reflective: true},
+		"generated":  {},
+	}
+
+	for _, s := range samples {
+		t.Run(s.name, func(t *testing.T) {
+			if _, ok := s.value.(codec.Marshaler); !ok {
+				t.Fatalf("%T has no generated MarshalCodec", s.value)
+			}
+			if _, ok := s.new().(codec.Unmarshaler); !ok {
+				t.Fatalf("%T has no generated UnmarshalCodec", s.new())
+			}
+
+			want, err := reflective.Marshall(s.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := generated.Marshall(s.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(want, got) {
+				t.Fatalf("encodings differ\nreflective: %x\ngenerated:  %x", want, got)
+			}
+
+			for name, dec := range decoders {
+				decoded := s.new()
+				if err := dec.Unmarshall(want, decoded); err != nil {
+					t.Fatalf("%s decoder: %s", name, err)
+				}
+				if value := reflect.ValueOf(decoded).Elem().Interface(); !reflect.DeepEqual(value, s.value) {
+					t.Errorf("%s decoder returned %+v, want %+v", name, value, s.value)
+				}
+			}
+		})
+	}
+}
+
+// Types added to codec_gen.go need a sample above
+func TestSamplesCoverGeneratedTypes(t *testing.T) {
+	file, err := parser.ParseFile(token.NewFileSet(), "codec_gen.go", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	covered := map[string]bool{}
+	for _, s := range samples {
+		covered[reflect.TypeOf(s.value).Name()] = true
+	}
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Recv == nil || fn.Name.Name != "MarshalCodec" {
+			continue
+		}
+		if ident, ok := fn.Recv.List[0].Type.(*ast.Ident); ok && !covered[ident.Name] {
+			t.Errorf("no sample of %s", ident.Name)
+		}
+	}
+}
+
+// Every field of the models is tagged with a numeric ID, so no Go field name is sent on the wire
+func TestModelsEncodeFieldIDs(t *testing.T) {
+	for _, s := range samples {
+		t.Run(s.name, func(t *testing.T) {
+			data, err := (&codec.Encoder{}).Marshall(s.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			typ := reflect.TypeOf(s.value)
+			for i := 0; i < typ.NumField(); i++ {
+				field := typ.Field(i)
+				if id, _, _, err := codec.ParseTag(field.Tag.Get(codec.TagName)); err != nil || id == 0 {
+					t.Errorf("%s.%s is not tagged with a codec id", typ.Name(), field.Name)
+				}
+				if bytes.Contains(data, []byte(field.Name)) {
+					t.Errorf("encoding contains the field name %q: %x", field.Name, data)
+				}
+			}
+		})
+	}
+}
+
+// Peers that predate the tags send field names, which are still accepted
+func TestModelsDecodeFieldNames(t *testing.T) {
+	type untaggedMoney struct {
+		Units    int64
+		Currency Currency
+	}
+	type untaggedTransferReq struct {
+		AccountNumber     int
+		Name              string
+		Password          string
+		Amount            untaggedMoney
+		DestAccountNumber int
+		Memo              *string
+	}
+
+	want := TransferReq{AccountNumber: 42, DestAccountNumber: 4242, Name: "Alice Tan", Password: "hunter22", Amount: NewMoney(500, "SGD"), Memo: &memo}
+	named, err := (&codec.Encoder{Reflective: true}).Marshall(untaggedTransferReq{
+		AccountNumber: 42, DestAccountNumber: 4242, Name: "Alice Tan", Password: "hunter22", Amount: untaggedMoney{500, "SGD"}, Memo: &memo,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	compact, err := (&codec.Encoder{}).Marshall(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(compact) >= len(named) {
+		t.Errorf("tagged encoding is %d bytes, not smaller than the %d bytes with field names", len(compact), len(named))
+	}
+
+	for name, dec := range map[string]*codec.Decoder{"reflective": {Reflective: true}, "generated": {}} {
+		got := TransferReq{}
+		if err := dec.Unmarshall(named, &got); err != nil {
+			t.Fatalf("%s decoder: %s", name, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s decoder returned %+v, want %+v", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,3 @@
+package models
+
+//go:generate go run ../../cmd/codecgen -output codec_gen.go
@@ -1,8 +1,8 @@
 package models
 
 type GetBalanceReq struct {
-	AccountNumber int
-	Name          string
-	Password      string
-	Currency      string
+	AccountNumber int      `codec:"1"`
+	Name          string   `codec:"2"`
+	Password      string   `codec:"3"`
+	Currency      Currency `codec:"4"`
 }
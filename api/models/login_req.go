@@ -0,0 +1,7 @@
+package models
+
+type LoginReq struct {
+	AccountNumber int    `codec:"1"`
+	Name          string `codec:"2"`
+	Password      string `codec:"3"`
+}
@@ -0,0 +1,139 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Money is an exact amount in the minor units (e.g. cents) of its currency
+type Money struct {
+	Units    int64    `codec:"1"`
+	Currency Currency `codec:"2"`
+}
+
+func NewMoney(units int64, currency Currency) Money {
+	return Money{Units: units, Currency: currency}
+}
+
+// ParseMoney parses a decimal amount such as "10.30", rejecting more decimal places than the currency has
+func ParseMoney(amount string, currency Currency) (Money, error) {
+	s := strings.TrimSpace(amount)
+	negative := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "-"), "+")
+
+	whole, frac := s, ""
+	if idx := strings.IndexByte(s, '.'); idx >= 0 {
+		whole, frac = s[:idx], s[idx+1:]
+	}
+	if whole == "" && frac == "" {
+		return Money{}, fmt.Errorf("invalid amount %q", amount)
+	}
+
+	digits := currency.MinorUnits()
+	if len(frac) > digits && digits == 0 {
+		return Money{}, fmt.Errorf("%s amounts cannot have decimal places", currency)
+	}
+	if len(frac) > digits {
+		return Money{}, fmt.Errorf("%s amounts have at most %d decimal places", currency, digits)
+	}
+
+	var units int64
+	for _, c := range whole + frac + strings.Repeat("0", digits-len(frac)) {
+		if c < '0' || c > '9' {
+			return Money{}, fmt.Errorf("invalid amount %q", amount)
+		}
+		if units > (math.MaxInt64-int64(c-'0'))/10 {
+			return Money{}, fmt.Errorf("amount %q is too large", amount)
+		}
+		units = units*10 + int64(c-'0')
+	}
+
+	if negative {
+		units = -units
+	}
+	return Money{Units: units, Currency: currency}, nil
+}
+
+// Decimal formats the amount without its currency, e.g. "10.30"
+func (m Money) Decimal() string {
+	digits := m.Currency.MinorUnits()
+	sign := ""
+	units := uint64(m.Units)
+	if m.Units < 0 {
+		sign = "-"
+		units = uint64(-m.Units) // Wraps to the right magnitude for math.MinInt64
+	}
+
+	s := fmt.Sprintf("%0*d", digits+1, units)
+	if digits == 0 {
+		return sign + s
+	}
+	return sign + s[:len(s)-digits] + "." + s[len(s)-digits:]
+}
+
+func (m Money) String() string {
+	return fmt.Sprintf("%s %s", m.Decimal(), m.Currency)
+}
+
+func (m Money) IsPositive() bool {
+	return m.Units > 0
+}
+
+func (m Money) IsNegative() bool {
+	return m.Units < 0
+}
+
+func (m Money) Neg() Money {
+	return Money{Units: -m.Units, Currency: m.Currency}
+}
+
+// Add fails instead of mixing currencies or overflowing
+func (m Money) Add(other Money) (Money, error) {
+	if m.Currency != other.Currency {
+		return Money{}, fmt.Errorf("cannot add %s to %s", other.Currency, m.Currency)
+	}
+	if (other.Units > 0 && m.Units > math.MaxInt64-other.Units) ||
+		(other.Units < 0 && m.Units < math.MinInt64-other.Units) {
+		return Money{}, errors.New("amount is too large")
+	}
+	return Money{Units: m.Units + other.Units, Currency: m.Currency}, nil
+}
+
+func (m Money) Sub(other Money) (Money, error) {
+	if other.Units == math.MinInt64 {
+		return Money{}, errors.New("amount is too large")
+	}
+	return m.Add(other.Neg())
+}
+
+// Encoded in JSON as a string like "10.30 SGD"
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.String())
+}
+
+func (m *Money) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return errors.New(`money must be a string like "10.30 SGD"`)
+	}
+
+	fields := strings.Fields(s)
+	if len(fields) != 2 {
+		return fmt.Errorf(`invalid money %q, expected a string like "10.30 SGD"`, s)
+	}
+
+	currency := Currency(fields[1])
+	if err := currency.Validate(); err != nil {
+		return err
+	}
+
+	parsed, err := ParseMoney(fields[0], currency)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
@@ -1,5 +1,5 @@
 package models
 
 type MonitorReq struct {
-	Interval int
+	Interval int `codec:"1"`
 }
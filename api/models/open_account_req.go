@@ -1,9 +1,8 @@
 package models
 
 type OpenAccountReq struct {
-	AccountNumber  int
-	Name           string
-	Password       string
-	Currency       string
-	InitialBalance float64
+	AccountNumber  int    `codec:"1"`
+	Name           string `codec:"2"`
+	Password       string `codec:"3"`
+	InitialBalance Money  `codec:"4"` // Also sets the account currency
 }
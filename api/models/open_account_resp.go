@@ -1,5 +1,5 @@
 package models
 
 type OpenAccountResp struct {
-	Message string
+	Message string `codec:"1"`
 }
@@ -0,0 +1,11 @@
+package models
+
+// Previews a transfer without moving money. The source account is authenticated as for a transfer, so that
+// quotes do not reveal which accounts exist to anyone else.
+type QuoteTransferReq struct {
+	AccountNumber     int    `codec:"1"`
+	Name              string `codec:"2"`
+	Password          string `codec:"3"`
+	Amount            Money  `codec:"4"` // In the source account currency
+	DestAccountNumber int    `codec:"5"`
+}
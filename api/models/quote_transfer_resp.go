@@ -0,0 +1,6 @@
+package models
+
+type QuoteTransferResp struct {
+	Received Money  `codec:"1"` // Amount credited to the destination account, in its currency
+	Rate     string `codec:"2"` // Destination currency units per source currency unit
+}
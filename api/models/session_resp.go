@@ -0,0 +1,7 @@
+package models
+
+// SessionResp is the reply to a login or refresh
+type SessionResp struct {
+	Token     string `codec:"1"`
+	ExpiresIn int    `codec:"2"` // Seconds until the token expires unless refreshed
+}
@@ -1,10 +1,10 @@
 package models
 
 type TransferReq struct {
-	AccountNumber     int
-	Name              string
-	Password          string
-	Currency          string
-	Amount            float64
-	DestAccountNumber int
+	AccountNumber     int     `codec:"1"`
+	Name              string  `codec:"2"`
+	Password          string  `codec:"3"`
+	Amount            Money   `codec:"4"` // In the source account currency
+	DestAccountNumber int     `codec:"5"`
+	Memo              *string `codec:"6"` // Optional, nil if not given
 }
@@ -1,5 +1,5 @@
 package models
 
 type TransferResp struct {
-	Balance float64
+	Balance Money `codec:"1"`
 }
@@ -1,9 +1,8 @@
 package models
 
 type UpdateBalanceReq struct {
-	AccountNumber int
-	Name          string
-	Password      string
-	Currency      string
-	Amount        float64
+	AccountNumber int    `codec:"1"`
+	Name          string `codec:"2"`
+	Password      string `codec:"3"`
+	Amount        Money  `codec:"4"` // In the account currency
 }
@@ -1,5 +1,5 @@
 package models
 
 type UpdateBalanceResp struct {
-	Balance float64
+	Balance Money `codec:"1"`
 }
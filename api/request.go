@@ -1,6 +1,8 @@
 package api
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"sync/atomic"
 	"time"
 )
@@ -8,19 +10,38 @@ import (
 var RSN *int64 = new(int64)
 
 type Request struct {
-	RSN    int
-	Method string
-	Data   interface{}
-	SentAt time.Time
+	RSN      int
+	ClientID string // Identifies the connection the request is sent on independently of its address, see NewClientID
+	AckRSN   int    // Replies to requests with a lower RSN have been received and can be discarded
+	Semantic string
+	Method   string
+	Data     interface{}
+	SentAt   time.Time
+	Token    string // Session from LoginAPI, used instead of the name and password in Data when set
 }
 
+// The RSN is assigned when the request is registered for sending, see NextRSN
 func NewRequest() Request {
-	req := Request{RSN: int(*RSN)}
-	atomic.AddInt64(RSN, 1)
+	req := Request{}
 	req.SentAt = time.Now()
 	return req
 }
 
+func NextRSN() int {
+	return int(atomic.AddInt64(RSN, 1) - 1)
+}
+
 func (req *Request) GetRSN() int {
 	return int(req.RSN)
 }
+
+// NewClientID returns an ID for one client's RSNs and acknowledgements, so that the server can filter its
+// duplicates. Clients in the same process each need their own, as their acknowledgements are independent.
+func NewClientID() string {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		// Fall back to the start time, which is unique enough for a single machine
+		return time.Now().Format("20060102150405.000000000")
+	}
+	return hex.EncodeToString(id)
+}
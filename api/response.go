@@ -1,6 +1,10 @@
 package api
 
+// Error message for requests whose session token is unknown or has expired
+const ErrMsgInvalidSession = "session expired, please log in again"
+
 type Response struct {
+	RSN    int
 	ErrMsg string
 	Data   interface{}
 }
@@ -8,3 +12,12 @@ type Response struct {
 func (r *Response) HasError() bool {
 	return r.ErrMsg != ""
 }
+
+func (r *Response) HasInvalidSession() bool {
+	return r.ErrMsg == ErrMsgInvalidSession
+}
+
+// Replies are matched to their request using the RSN echoed by the server
+func (r *Response) Matches(req Request) bool {
+	return r.RSN == req.RSN
+}
@@ -0,0 +1,53 @@
+package batch
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+type Summary struct {
+	Total    int
+	Passed   int
+	Failed   int
+	Skipped  int // Steps not run because the batch stopped early
+	Duration time.Duration
+}
+
+func Summarise(steps []Step, results []Result) Summary {
+	summary := Summary{Total: len(steps), Skipped: len(steps) - len(results)}
+	for _, result := range results {
+		if result.Passed {
+			summary.Passed++
+		} else {
+			summary.Failed++
+		}
+		summary.Duration += result.Duration
+	}
+	return summary
+}
+
+func (s Summary) OK() bool {
+	return s.Failed == 0 && s.Skipped == 0
+}
+
+// WriteReport prints one line per step followed by the summary
+func WriteReport(w io.Writer, steps []Step, results []Result) Summary {
+	for idx, result := range results {
+		status := "PASS"
+		if !result.Passed {
+			status = "FAIL"
+		}
+
+		fmt.Fprintf(w, "%s  %3d  %-20s line %-4d %8s", status, idx+1, result.Step.Name, result.Step.Line, result.Duration.Round(time.Millisecond))
+		if !result.Passed {
+			fmt.Fprintf(w, "  %s", result.Reason)
+		}
+		fmt.Fprintln(w)
+	}
+
+	summary := Summarise(steps, results)
+	fmt.Fprintf(w, "\n%d steps: %d passed, %d failed, %d skipped in %s\n",
+		summary.Total, summary.Passed, summary.Failed, summary.Skipped, summary.Duration.Round(time.Millisecond))
+	return summary
+}
@@ -0,0 +1,114 @@
+package batch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	apiModels "github.com/chiahsoon/cz4013-client/api/models"
+	"github.com/chiahsoon/cz4013-client/client"
+)
+
+type Result struct {
+	Step     Step
+	Passed   bool
+	Reason   string // Why the step failed
+	Response interface{}
+	Err      error
+	Duration time.Duration
+}
+
+type Runner struct {
+	Client        *client.Client
+	StopOnFailure bool
+}
+
+// Run executes steps in order and checks their expectations
+func (r *Runner) Run(ctx context.Context, steps []Step) []Result {
+	results := []Result{}
+	for _, step := range steps {
+		if ctx.Err() != nil {
+			break
+		}
+
+		result := r.runStep(ctx, step)
+		results = append(results, result)
+		if !result.Passed && r.StopOnFailure {
+			break
+		}
+	}
+	return results
+}
+
+func (r *Runner) runStep(ctx context.Context, step Step) Result {
+	result := Result{Step: step}
+	req, err := step.request()
+	if err != nil {
+		result.Reason = err.Error()
+		return result
+	}
+
+	resp := responseTypes[step.Method]()
+	start := time.Now()
+	result.Err = r.Client.Call(ctx, step.Method, req, resp)
+	result.Duration = time.Since(start)
+	result.Response = derefValue(resp)
+	result.Reason = check(step.Expect, result.Response, result.Err)
+	result.Passed = result.Reason == ""
+	return result
+}
+
+// Returns why the outcome does not meet the expectation, or an empty string if it does
+func check(expect Expectation, resp interface{}, err error) string {
+	var serverErr *client.ServerError
+	if err != nil && !errors.As(err, &serverErr) {
+		return fmt.Sprintf("request failed: %s", err)
+	}
+
+	if expect.Error != "" {
+		if serverErr == nil {
+			return fmt.Sprintf("expected error containing %q but the request succeeded", expect.Error)
+		}
+		if !strings.Contains(serverErr.Msg, expect.Error) {
+			return fmt.Sprintf("expected error containing %q, got %q", expect.Error, serverErr.Msg)
+		}
+		return ""
+	}
+
+	if serverErr != nil {
+		return fmt.Sprintf("unexpected error: %s", serverErr.Msg)
+	}
+
+	if expect.Balance != nil {
+		balance, ok := balanceOf(resp)
+		if !ok {
+			return "response has no balance"
+		}
+		if balance != *expect.Balance {
+			return fmt.Sprintf("expected balance %s, got %s", *expect.Balance, balance)
+		}
+	}
+
+	return ""
+}
+
+func balanceOf(resp interface{}) (apiModels.Money, bool) {
+	rv := reflect.ValueOf(resp)
+	if rv.Kind() != reflect.Struct {
+		return apiModels.Money{}, false
+	}
+
+	field := rv.FieldByName("Balance")
+	if !field.IsValid() {
+		return apiModels.Money{}, false
+	}
+	balance, ok := field.Interface().(apiModels.Money)
+	return balance, ok
+}
+
+func derefValue(ptr interface{}) interface{} {
+	return reflect.ValueOf(ptr).Elem().Interface()
+}
@@ -0,0 +1,101 @@
+package batch
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/chiahsoon/cz4013-client/api"
+	apiModels "github.com/chiahsoon/cz4013-client/api/models"
+)
+
+// Step is a single operation in a batch file, encoded as one JSON object per line, e.g.
+// {"name": "deposit", "method": "update_balance", "data": {"AccountNumber": 1, "Amount": "5.00 SGD", ...}, "expect": {"balance": "15.00 SGD"}}
+type Step struct {
+	Name   string          `json:"name"`
+	Method api.APIMethod   `json:"method"`
+	Data   json.RawMessage `json:"data"`
+	Expect Expectation     `json:"expect"`
+	Line   int             `json:"-"`
+}
+
+type Expectation struct {
+	Balance *apiModels.Money `json:"balance"` // Balance field of the response
+	Error   string           `json:"error"`   // Substring of the server error, the step must fail if set
+}
+
+// Request and response models of every method that can be batched
+var requestTypes = map[api.APIMethod]func() interface{}{
+	api.OpenAccountAPI:   func() interface{} { return &apiModels.OpenAccountReq{} },
+	api.CloseAccountAPI:  func() interface{} { return &apiModels.CloseAccountReq{} },
+	api.GetBalanceAPI:    func() interface{} { return &apiModels.GetBalanceReq{} },
+	api.UpdateBalanceAPI: func() interface{} { return &apiModels.UpdateBalanceReq{} },
+	api.TransferAPI:      func() interface{} { return &apiModels.TransferReq{} },
+	api.CheckStateAPI:    func() interface{} { return nil },
+}
+
+var responseTypes = map[api.APIMethod]func() interface{}{
+	api.OpenAccountAPI:   func() interface{} { return &apiModels.OpenAccountResp{} },
+	api.CloseAccountAPI:  func() interface{} { return &apiModels.CloseAccountResp{} },
+	api.GetBalanceAPI:    func() interface{} { return &apiModels.GetBalanceResp{} },
+	api.UpdateBalanceAPI: func() interface{} { return &apiModels.UpdateBalanceResp{} },
+	api.TransferAPI:      func() interface{} { return &apiModels.TransferResp{} },
+	api.CheckStateAPI:    func() interface{} { return &[]apiModels.Account{} },
+}
+
+// Parse reads steps from JSON lines, skipping blank lines and lines starting with #
+func Parse(r io.Reader) ([]Step, error) {
+	steps := []Step{}
+	scanner := bufio.NewScanner(r)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+
+		step := Step{}
+		decoder := json.NewDecoder(bytes.NewReader(line))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&step); err != nil {
+			return nil, fmt.Errorf("line %d: %s", lineNum, err)
+		}
+
+		if _, ok := requestTypes[step.Method]; !ok {
+			return nil, fmt.Errorf("line %d: method %q cannot be batched", lineNum, step.Method)
+		}
+
+		step.Line = lineNum
+		if step.Name == "" {
+			step.Name = string(step.Method)
+		}
+		steps = append(steps, step)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return steps, nil
+}
+
+// Decodes the step data into the request model of its method
+func (s Step) request() (interface{}, error) {
+	req := requestTypes[s.Method]()
+	if req == nil {
+		return nil, nil
+	}
+
+	if len(s.Data) > 0 {
+		decoder := json.NewDecoder(bytes.NewReader(s.Data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(req); err != nil {
+			return nil, fmt.Errorf("invalid data: %s", err)
+		}
+	}
+
+	// Send the model itself rather than a pointer to it
+	return derefValue(req), nil
+}
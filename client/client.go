@@ -0,0 +1,118 @@
+package client
+
+import (
+	"context"
+	"net"
+	"sync"
+
+	"github.com/chiahsoon/cz4013-client/api"
+	"github.com/chiahsoon/cz4013-client/api/codec"
+	apiModels "github.com/chiahsoon/cz4013-client/api/models"
+	"github.com/chiahsoon/cz4013-client/services"
+)
+
+// Client is a typed wrapper around the bank API, safe for concurrent use
+type Client struct {
+	mux     *services.Multiplexer
+	connSvc *services.ConnectionService
+
+	mu      sync.Mutex
+	session *Session
+}
+
+// NewClient takes over reading from conn, closing conn stops the client
+func NewClient(conn net.Conn, connSvc *services.ConnectionService) *Client {
+	return &Client{mux: connSvc.Multiplex(conn), connSvc: connSvc}
+}
+
+func (c *Client) OpenAccount(ctx context.Context, req apiModels.OpenAccountReq) (apiModels.OpenAccountResp, error) {
+	var resp apiModels.OpenAccountResp
+	err := c.Call(ctx, api.OpenAccountAPI, req, &resp)
+	return resp, err
+}
+
+func (c *Client) CloseAccount(ctx context.Context, req apiModels.CloseAccountReq) (apiModels.CloseAccountResp, error) {
+	var resp apiModels.CloseAccountResp
+	session, loggedIn := c.Session()
+	err := c.Call(ctx, api.CloseAccountAPI, req, &resp)
+	if err == nil && loggedIn && (req.AccountNumber == 0 || req.AccountNumber == session.AccountNumber) {
+		// The server ends the sessions of closed accounts
+		c.endSession(session.Token)
+	}
+	return resp, err
+}
+
+func (c *Client) GetBalance(ctx context.Context, req apiModels.GetBalanceReq) (apiModels.GetBalanceResp, error) {
+	var resp apiModels.GetBalanceResp
+	err := c.Call(ctx, api.GetBalanceAPI, req, &resp)
+	return resp, err
+}
+
+func (c *Client) Deposit(ctx context.Context, req apiModels.UpdateBalanceReq) (apiModels.UpdateBalanceResp, error) {
+	var resp apiModels.UpdateBalanceResp
+	err := c.Call(ctx, api.UpdateBalanceAPI, req, &resp)
+	return resp, err
+}
+
+// req.Amount is the (positive) amount to withdraw
+func (c *Client) Withdraw(ctx context.Context, req apiModels.UpdateBalanceReq) (apiModels.UpdateBalanceResp, error) {
+	req.Amount = req.Amount.Neg()
+	var resp apiModels.UpdateBalanceResp
+	err := c.Call(ctx, api.UpdateBalanceAPI, req, &resp)
+	return resp, err
+}
+
+func (c *Client) Transfer(ctx context.Context, req apiModels.TransferReq) (apiModels.TransferResp, error) {
+	var resp apiModels.TransferResp
+	err := c.Call(ctx, api.TransferAPI, req, &resp)
+	return resp, err
+}
+
+// QuoteTransfer previews the amount a transfer would credit, converted by the server's exchange rates
+func (c *Client) QuoteTransfer(ctx context.Context, req apiModels.QuoteTransferReq) (apiModels.QuoteTransferResp, error) {
+	var resp apiModels.QuoteTransferResp
+	err := c.Call(ctx, api.QuoteTransferAPI, req, &resp)
+	return resp, err
+}
+
+func (c *Client) CheckState(ctx context.Context) ([]apiModels.Account, error) {
+	var resp []apiModels.Account
+	err := c.Call(ctx, api.CheckStateAPI, nil, &resp)
+	return resp, err
+}
+
+// Call sends data to method and decodes the reply data into dest, which may be nil to discard it.
+// The session token is attached if logged in, see Login.
+func (c *Client) Call(ctx context.Context, method api.APIMethod, data interface{}, dest interface{}) error {
+	token, err := c.token(ctx)
+	if err != nil {
+		return err
+	}
+	return c.call(ctx, method, data, dest, token)
+}
+
+func (c *Client) call(ctx context.Context, method api.APIMethod, data interface{}, dest interface{}, token string) error {
+	req := api.NewRequest()
+	req.Method = string(method)
+	req.Data = data
+	req.Token = token
+
+	resp := api.Response{}
+	if err := c.connSvc.Fetch(ctx, c.mux, req, &resp); err != nil {
+		return err
+	}
+
+	if resp.HasInvalidSession() {
+		c.endSession(token)
+	}
+	if resp.HasError() {
+		return &ServerError{Method: method, Msg: resp.ErrMsg}
+	}
+
+	if dest == nil {
+		return nil
+	}
+
+	cd := codec.Codec{}
+	return cd.DecodeAsInterface(resp.Data, dest)
+}
@@ -0,0 +1,22 @@
+package client
+
+import (
+	"errors"
+
+	"github.com/chiahsoon/cz4013-client/api"
+)
+
+var ErrNotLoggedIn = errors.New("not logged in")
+
+// ErrSessionExpired is returned instead of sending a request once the session has expired
+var ErrSessionExpired = errors.New(api.ErrMsgInvalidSession)
+
+// ServerError is returned when the server rejects a request
+type ServerError struct {
+	Method api.APIMethod
+	Msg    string
+}
+
+func (e *ServerError) Error() string {
+	return e.Msg
+}
@@ -0,0 +1,82 @@
+package client
+
+import (
+	"context"
+	"time"
+
+	"github.com/chiahsoon/cz4013-client/api"
+	"github.com/chiahsoon/cz4013-client/api/codec"
+	apiModels "github.com/chiahsoon/cz4013-client/api/models"
+)
+
+type MonitorUpdate struct {
+	Message string
+	Err     error // Set when a callback could not be decoded
+}
+
+// Monitor registers for updates and streams them until the interval ends or ctx is done.
+/*
+	- The server's confirmation is the first update
+	- Callbacks arrive on the shared connection, so other calls can still be made meanwhile
+	- Undecodable callbacks are reported and skipped
+*/
+func (c *Client) Monitor(ctx context.Context, req apiModels.MonitorReq) (<-chan MonitorUpdate, error) {
+	// Callbacks queued before registering belong to an earlier interval
+	c.drainCallbacks()
+
+	var confirmation string
+	if err := c.Call(ctx, api.MonitorAPI, req, &confirmation); err != nil {
+		return nil, err
+	}
+
+	updates := make(chan MonitorUpdate)
+	intervalEnd := time.Now().Add(time.Duration(req.Interval) * time.Second)
+	go c.listenForCallbacks(ctx, intervalEnd, confirmation, updates)
+	return updates, nil
+}
+
+func (c *Client) listenForCallbacks(ctx context.Context, intervalEnd time.Time, confirmation string, updates chan<- MonitorUpdate) {
+	defer close(updates)
+	c.send(ctx, updates, MonitorUpdate{Message: confirmation})
+
+	timer := time.NewTimer(time.Until(intervalEnd))
+	defer timer.Stop()
+	cd := codec.Codec{}
+	for {
+		var resp api.Response
+		select {
+		case resp = <-c.mux.Callbacks():
+		case <-timer.C:
+			return
+		case <-ctx.Done():
+			// Cancelling ends the interval early
+			return
+		}
+
+		// Monitoring callbacks will always be string data
+		var respData string
+		if err := cd.DecodeAsInterface(resp.Data, &respData); err != nil {
+			c.send(ctx, updates, MonitorUpdate{Err: err})
+			continue
+		}
+
+		c.send(ctx, updates, MonitorUpdate{Message: respData})
+	}
+}
+
+func (c *Client) drainCallbacks() {
+	for {
+		select {
+		case <-c.mux.Callbacks():
+		default:
+			return
+		}
+	}
+}
+
+func (c *Client) send(ctx context.Context, updates chan<- MonitorUpdate, update MonitorUpdate) {
+	select {
+	case updates <- update:
+	case <-ctx.Done():
+	}
+}
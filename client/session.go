@@ -0,0 +1,101 @@
+package client
+
+import (
+	"context"
+	"time"
+
+	"github.com/chiahsoon/cz4013-client/api"
+	apiModels "github.com/chiahsoon/cz4013-client/api/models"
+)
+
+// Sessions are refreshed before a call when they expire within this long
+const refreshBefore = 30 * time.Second
+
+// Session is a login the client attaches to its requests in place of a name and password
+type Session struct {
+	AccountNumber int
+	Token         string
+	ExpiresAt     time.Time // Measured on the client's clock from when the server replied
+}
+
+// Login starts a session for an account, replacing any current one
+func (c *Client) Login(ctx context.Context, req apiModels.LoginReq) (Session, error) {
+	var resp apiModels.SessionResp
+	if err := c.call(ctx, api.LoginAPI, req, &resp, ""); err != nil {
+		return Session{}, err
+	}
+
+	s := Session{AccountNumber: req.AccountNumber, Token: resp.Token, ExpiresAt: expiresAt(resp)}
+	c.mu.Lock()
+	c.session = &s
+	c.mu.Unlock()
+	return s, nil
+}
+
+// Logout ends the current session. The session is forgotten even if the server cannot be reached, as it
+// expires on its own.
+func (c *Client) Logout(ctx context.Context) error {
+	c.mu.Lock()
+	s := c.session
+	c.session = nil
+	c.mu.Unlock()
+	if s == nil {
+		return ErrNotLoggedIn
+	}
+
+	return c.call(ctx, api.LogoutAPI, nil, nil, s.Token)
+}
+
+// Session returns the current session, if logged in
+func (c *Client) Session() (Session, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.session == nil || time.Now().After(c.session.ExpiresAt) {
+		return Session{}, false
+	}
+	return *c.session, true
+}
+
+// token returns the token to attach to the next request, refreshing it first if it is about to expire
+func (c *Client) token(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	if c.session == nil {
+		c.mu.Unlock()
+		return "", nil
+	}
+	s := *c.session
+	c.mu.Unlock()
+
+	if time.Now().After(s.ExpiresAt) {
+		c.endSession(s.Token)
+		return "", ErrSessionExpired
+	}
+	if time.Until(s.ExpiresAt) > refreshBefore {
+		return s.Token, nil
+	}
+
+	var resp apiModels.SessionResp
+	if err := c.call(ctx, api.RefreshAPI, nil, &resp, s.Token); err != nil {
+		return "", err
+	}
+
+	c.mu.Lock()
+	if c.session != nil && c.session.Token == resp.Token {
+		c.session.ExpiresAt = expiresAt(resp)
+	}
+	c.mu.Unlock()
+	return resp.Token, nil
+}
+
+// Forgets the session if it is still token, e.g. once the server no longer accepts it
+func (c *Client) endSession(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.session != nil && c.session.Token == token {
+		c.session = nil
+	}
+}
+
+func expiresAt(resp apiModels.SessionResp) time.Time {
+	return time.Now().Add(time.Duration(resp.ExpiresIn) * time.Second)
+}
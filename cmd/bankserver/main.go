@@ -0,0 +1,86 @@
+package main
+
+import (
+	"crypto/ecdh"
+	"errors"
+	"flag"
+	"log"
+	"net"
+	"os"
+
+	"github.com/chiahsoon/cz4013-client/config"
+	"github.com/chiahsoon/cz4013-client/server"
+	"github.com/chiahsoon/cz4013-client/transport"
+)
+
+func main() {
+	host := flag.String("host", "localhost", "IP address to listen on")
+	port := flag.String("port", "5000", "Port to listen on")
+	secure := flag.Bool("secure", false, "Only accept clients using the secure channel")
+	keyPath := flag.String("key", "", "File with the hex encoded secure channel key, created if missing. A new key is used every run if empty")
+	transportName := flag.String("transport", string(config.UDP), "How requests are carried - udp (Default), tcp, unix")
+	socket := flag.String("socket", transport.DefaultSocketPath(), "Path of the socket to listen on with -transport unix")
+	sessionTTL := flag.Duration("session-ttl", server.DefaultSessionTTL, "How long a login lasts without being refreshed")
+	convert := flag.Bool("exchange-rates", false, "Convert transfers between accounts in different currencies at fixed rates instead of rejecting them")
+	historyTTL := flag.Duration("history-ttl", server.DefaultHistoryTTL, "How long replies are kept for duplicate filtering after a client's last at-most-once request")
+	flag.Parse()
+
+	kind := config.Transport(*transportName)
+	if err := kind.Validate(); err != nil {
+		log.Fatal(err)
+	}
+	addr := net.JoinHostPort(*host, *port)
+	if kind == config.Unix {
+		addr = *socket
+	}
+
+	conn, err := transport.Listen(kind, addr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer conn.Close()
+
+	bank := server.NewBank()
+	bank.SessionTTL = *sessionTTL
+	if *convert {
+		bank.Rates = server.DefaultExchangeRates()
+	}
+	srv := server.NewServer(bank)
+	srv.Logger = log.New(os.Stderr, "bankserver: ", log.LstdFlags)
+	srv.HistoryTTL = *historyTTL
+	if *secure {
+		if srv.Key, err = loadKey(*keyPath); err != nil {
+			log.Fatal(err)
+		}
+		srv.Logger.Printf("secure channel key %s", transport.FormatKey(srv.Key.PublicKey().Bytes()))
+	}
+
+	srv.Logger.Printf("listening on %s over %s", conn.LocalAddr(), kind)
+	if err := srv.Serve(conn); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// Clients can only pin the key if it is kept across runs
+func loadKey(path string) (*ecdh.PrivateKey, error) {
+	if path == "" {
+		return transport.GenerateKey()
+	}
+
+	data, err := os.ReadFile(path)
+	if err == nil {
+		return transport.ParseKey(string(data))
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	key, err := transport.GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, []byte(transport.FormatKey(key.Bytes())+"\n"), 0600); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
@@ -0,0 +1,276 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+
+	apiModels "github.com/chiahsoon/cz4013-client/api/models"
+	"github.com/chiahsoon/cz4013-client/services"
+)
+
+func runOpenAccount(ctx context.Context, e *Env, args []string) error {
+	req := apiModels.OpenAccountReq{}
+	fs := e.newFlagSet("open")
+	fs.StringVar(&req.Name, "name", "", "Account holder name")
+	currency := e.addCurrencyFlag(fs, "Account currency")
+	balance := fs.String("balance", "0", "Initial account balance")
+	pf := e.addPasswordFlags(fs)
+	if err := e.parse(fs, args, "name"); err != nil {
+		return err
+	}
+
+	currencyCode, err := parseCurrency(*currency)
+	if err != nil {
+		return err
+	}
+	if req.InitialBalance, err = parseMoney("balance", *balance, currencyCode); err != nil {
+		return err
+	}
+
+	password, err := e.readPassword(pf)
+	if err != nil {
+		return err
+	}
+	if err := services.ValidatePassword(password); err != nil {
+		return &usageError{fmt.Sprintf("--password: %s", err)}
+	}
+	req.Password = password
+
+	c, err := e.client()
+	if err != nil {
+		return err
+	}
+	resp, err := c.OpenAccount(ctx, req)
+	if err != nil {
+		return err
+	}
+	e.print(resp)
+	return nil
+}
+
+func runCloseAccount(ctx context.Context, e *Env, args []string) error {
+	req := apiModels.CloseAccountReq{}
+	fs := e.newFlagSet("close")
+	fs.IntVar(&req.AccountNumber, "account", 0, "Account number")
+	fs.StringVar(&req.Name, "name", "", "Account holder name")
+	pf := e.addPasswordFlags(fs)
+	if err := e.parse(fs, args, "account", "name"); err != nil {
+		return err
+	}
+
+	password, err := e.readPassword(pf)
+	if err != nil {
+		return err
+	}
+	req.Password = password
+
+	c, err := e.client()
+	if err != nil {
+		return err
+	}
+	resp, err := c.CloseAccount(ctx, req)
+	if err != nil {
+		return err
+	}
+	e.print(resp)
+	return nil
+}
+
+func runGetBalance(ctx context.Context, e *Env, args []string) error {
+	req := apiModels.GetBalanceReq{}
+	fs := e.newFlagSet("balance")
+	fs.IntVar(&req.AccountNumber, "account", 0, "Account number")
+	fs.StringVar(&req.Name, "name", "", "Account holder name")
+	currency := e.addCurrencyFlag(fs, "Account currency")
+	pf := e.addPasswordFlags(fs)
+	if err := e.parse(fs, args, "account", "name"); err != nil {
+		return err
+	}
+
+	currencyCode, err := parseCurrency(*currency)
+	if err != nil {
+		return err
+	}
+	req.Currency = currencyCode
+
+	password, err := e.readPassword(pf)
+	if err != nil {
+		return err
+	}
+	req.Password = password
+
+	c, err := e.client()
+	if err != nil {
+		return err
+	}
+	resp, err := c.GetBalance(ctx, req)
+	if err != nil {
+		return err
+	}
+	e.print(resp)
+	return nil
+}
+
+func runDeposit(ctx context.Context, e *Env, args []string) error {
+	req, err := e.parseUpdateBalance("deposit", args)
+	if err != nil {
+		return err
+	}
+
+	c, err := e.client()
+	if err != nil {
+		return err
+	}
+	resp, err := c.Deposit(ctx, req)
+	if err != nil {
+		return err
+	}
+	e.print(resp)
+	return nil
+}
+
+func runWithdraw(ctx context.Context, e *Env, args []string) error {
+	req, err := e.parseUpdateBalance("withdraw", args)
+	if err != nil {
+		return err
+	}
+
+	c, err := e.client()
+	if err != nil {
+		return err
+	}
+	resp, err := c.Withdraw(ctx, req)
+	if err != nil {
+		return err
+	}
+	e.print(resp)
+	return nil
+}
+
+func (e *Env) parseUpdateBalance(name string, args []string) (apiModels.UpdateBalanceReq, error) {
+	req := apiModels.UpdateBalanceReq{}
+	fs := e.newFlagSet(name)
+	fs.IntVar(&req.AccountNumber, "account", 0, "Account number")
+	fs.StringVar(&req.Name, "name", "", "Account holder name")
+	currency := e.addCurrencyFlag(fs, "Account currency")
+	amount := fs.String("amount", "", "Amount to "+name)
+	pf := e.addPasswordFlags(fs)
+	if err := e.parse(fs, args, "account", "name", "amount"); err != nil {
+		return req, err
+	}
+
+	currencyCode, err := parseCurrency(*currency)
+	if err != nil {
+		return req, err
+	}
+	if req.Amount, err = parseMoney("amount", *amount, currencyCode); err != nil {
+		return req, err
+	}
+	if !req.Amount.IsPositive() {
+		return req, &usageError{"--amount must be positive"}
+	}
+
+	password, err := e.readPassword(pf)
+	if err != nil {
+		return req, err
+	}
+	req.Password = password
+	return req, nil
+}
+
+func runTransfer(ctx context.Context, e *Env, args []string) error {
+	req := apiModels.TransferReq{}
+	fs := e.newFlagSet("transfer")
+	fs.IntVar(&req.AccountNumber, "account", 0, "Source account number")
+	fs.IntVar(&req.DestAccountNumber, "dest", 0, "Destination account number")
+	fs.StringVar(&req.Name, "name", "", "Source account holder name")
+	currency := e.addCurrencyFlag(fs, "Source account currency")
+	amount := fs.String("amount", "", "Amount to transfer")
+	memo := fs.String("memo", "", "Optional note shown to monitoring clients")
+	pf := e.addPasswordFlags(fs)
+	if err := e.parse(fs, args, "account", "dest", "name", "amount"); err != nil {
+		return err
+	}
+	if *memo != "" {
+		req.Memo = memo
+	}
+
+	currencyCode, err := parseCurrency(*currency)
+	if err != nil {
+		return err
+	}
+	if req.Amount, err = parseMoney("amount", *amount, currencyCode); err != nil {
+		return err
+	}
+	if !req.Amount.IsPositive() {
+		return &usageError{"--amount must be positive"}
+	}
+
+	password, err := e.readPassword(pf)
+	if err != nil {
+		return err
+	}
+	req.Password = password
+
+	c, err := e.client()
+	if err != nil {
+		return err
+	}
+	resp, err := c.Transfer(ctx, req)
+	if err != nil {
+		return err
+	}
+	e.print(resp)
+	return nil
+}
+
+func runMonitor(ctx context.Context, e *Env, args []string) error {
+	req := apiModels.MonitorReq{}
+	fs := e.newFlagSet("monitor")
+	fs.IntVar(&req.Interval, "interval", 0, "Monitoring interval in seconds")
+	if err := e.parse(fs, args, "interval"); err != nil {
+		return err
+	}
+
+	if req.Interval <= 0 {
+		return &usageError{"--interval must be larger than zero seconds"}
+	}
+
+	c, err := e.client()
+	if err != nil {
+		return err
+	}
+	updates, err := c.Monitor(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	// Report the last undecodable update, if any, after the stream ends
+	var lastErr error
+	for update := range updates {
+		if update.Err != nil {
+			lastErr = update.Err
+			continue
+		}
+		e.print(update.Message)
+	}
+	return lastErr
+}
+
+func runCheckState(ctx context.Context, e *Env, args []string) error {
+	fs := e.newFlagSet("check-state")
+	if err := e.parse(fs, args); err != nil {
+		return err
+	}
+
+	c, err := e.client()
+	if err != nil {
+		return err
+	}
+	accounts, err := c.CheckState(ctx)
+	if err != nil {
+		return err
+	}
+	e.print(accounts)
+	return nil
+}
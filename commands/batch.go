@@ -0,0 +1,42 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"os"
+
+	"github.com/chiahsoon/cz4013-client/batch"
+)
+
+var errCheckFailed = errors.New("check failed")
+
+func runBatch(ctx context.Context, e *Env, args []string) error {
+	fs := e.newFlagSet("batch")
+	file := fs.String("file", "", "JSON lines file of operations to run")
+	stopOnFailure := fs.Bool("stop-on-failure", false, "Stop at the first step that fails")
+	if err := e.parse(fs, args, "file"); err != nil {
+		return err
+	}
+
+	f, err := os.Open(*file)
+	if err != nil {
+		return &usageError{err.Error()}
+	}
+	defer f.Close()
+
+	steps, err := batch.Parse(f)
+	if err != nil {
+		return &usageError{err.Error()}
+	}
+
+	c, err := e.client()
+	if err != nil {
+		return err
+	}
+	runner := batch.Runner{Client: c, StopOnFailure: *stopOnFailure}
+	results := runner.Run(ctx, steps)
+	if summary := batch.WriteReport(e.Stdout, steps, results); !summary.OK() {
+		return errCheckFailed
+	}
+	return nil
+}
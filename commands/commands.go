@@ -0,0 +1,235 @@
+package commands
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+
+	apiModels "github.com/chiahsoon/cz4013-client/api/models"
+	"github.com/chiahsoon/cz4013-client/client"
+	"github.com/chiahsoon/cz4013-client/config"
+	"github.com/chiahsoon/cz4013-client/models"
+	"github.com/chiahsoon/cz4013-client/services"
+)
+
+// Exit codes returned by Run
+const (
+	ExitOK             = 0
+	ExitServerError    = 1 // The server rejected the request
+	ExitUsage          = 2 // Invalid command or flags
+	ExitTransportError = 3 // No valid reply was received
+	ExitCheckFailed    = 4 // A batch step did not meet its expectation
+)
+
+type usageError struct {
+	msg string
+}
+
+func (e *usageError) Error() string {
+	return e.msg
+}
+
+// Env is shared by every subcommand
+type Env struct {
+	Client *client.Client
+	// Sets up Client when a command first needs it, so that commands such as help and experiment --local run
+	// without connecting to -host/-port
+	Connect func() (*client.Client, error)
+	Config  *config.Config
+	Dial    func() (net.Conn, error) // Opens a new connection to the server
+	Stdin   io.Reader
+	Stdout  io.Writer
+	Stderr  io.Writer
+}
+
+type runFunc func(ctx context.Context, e *Env, args []string) error
+
+var runners = map[models.UserSelectedAction]runFunc{
+	models.OpenAccountAction:  runOpenAccount,
+	models.CloseAccountAction: runCloseAccount,
+	models.GetBalanceAction:   runGetBalance,
+	models.DepositAction:      runDeposit,
+	models.WithdrawAction:     runWithdraw,
+	models.TransferAction:     runTransfer,
+	models.MonitorAction:      runMonitor,
+	models.CheckStateAction:   runCheckState,
+}
+
+// Commands that do not correspond to a menu action
+var tools = []struct {
+	name        string
+	description string
+	run         runFunc
+}{
+	{"batch", "Run the operations in a JSON lines file", runBatch},
+	{"experiment", "Compare invocation semantics under simulated loss", runExperiment},
+}
+
+// Run executes a single subcommand, e.g. ["deposit", "--account", "12", ...], and returns the exit code
+func Run(ctx context.Context, e *Env, args []string) int {
+	if len(args) == 0 || args[0] == "help" {
+		e.printUsage()
+		return ExitUsage
+	}
+
+	var run runFunc
+	for _, tool := range tools {
+		if tool.name == args[0] {
+			run = tool.run
+		}
+	}
+	if action, err := models.ActionForCommand(args[0]); err == nil {
+		run = runners[action]
+	}
+	if run == nil {
+		fmt.Fprintf(e.Stderr, "unknown command %q\n", args[0])
+		e.printUsage()
+		return ExitUsage
+	}
+
+	err := run(ctx, e, args[1:])
+	if err == nil {
+		return ExitOK
+	}
+
+	var usageErr *usageError
+	var serverErr *client.ServerError
+	switch {
+	case errors.Is(err, flag.ErrHelp):
+		return ExitUsage
+	case errors.Is(err, errCheckFailed):
+		return ExitCheckFailed
+	case errors.As(err, &usageErr):
+		fmt.Fprintf(e.Stderr, "%s: %s\n", args[0], err)
+		return ExitUsage
+	case errors.As(err, &serverErr):
+		fmt.Fprintf(e.Stderr, "error: %s\n", err)
+		return ExitServerError
+	default:
+		fmt.Fprintf(e.Stderr, "error: %s\n", err)
+		return ExitTransportError
+	}
+}
+
+func (e *Env) client() (*client.Client, error) {
+	if e.Client == nil {
+		c, err := e.Connect()
+		if err != nil {
+			return nil, err
+		}
+		e.Client = c
+	}
+	return e.Client, nil
+}
+
+func (e *Env) printUsage() {
+	fmt.Fprintln(e.Stderr, "Usage: cz4013-client [flags] <command> [command flags]")
+	fmt.Fprintln(e.Stderr, "Commands:")
+	for _, action := range models.AllActions {
+		if action.Command() == "" {
+			continue
+		}
+		fmt.Fprintf(e.Stderr, "  %-12s %s\n", action.Command(), action.Description())
+	}
+	for _, tool := range tools {
+		fmt.Fprintf(e.Stderr, "  %-12s %s\n", tool.name, tool.description)
+	}
+	fmt.Fprintln(e.Stderr, "Run '<command> -h' for the flags of a command")
+}
+
+func (e *Env) newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(e.Stderr)
+	return fs
+}
+
+// Parses args and checks that every required flag was set
+func (e *Env) parse(fs *flag.FlagSet, args []string, required ...string) error {
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() > 0 {
+		return &usageError{fmt.Sprintf("unexpected argument %q", fs.Arg(0))}
+	}
+
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	for _, name := range required {
+		if !set[name] {
+			return &usageError{fmt.Sprintf("missing required flag --%s", name)}
+		}
+	}
+	return nil
+}
+
+// Password flags shared by every command that authenticates
+type passwordFlags struct {
+	password      string
+	passwordStdin bool
+}
+
+func (e *Env) addPasswordFlags(fs *flag.FlagSet) *passwordFlags {
+	pf := &passwordFlags{}
+	fs.StringVar(&pf.password, "password", "", "Account password (prefer --password-stdin)")
+	fs.BoolVar(&pf.passwordStdin, "password-stdin", false, "Read the password from the first line of stdin")
+	return pf
+}
+
+func (e *Env) readPassword(pf *passwordFlags) (string, error) {
+	if !pf.passwordStdin {
+		if pf.password == "" {
+			return "", &usageError{"one of --password or --password-stdin is required"}
+		}
+		return pf.password, nil
+	}
+
+	if pf.password != "" {
+		return "", &usageError{"--password and --password-stdin are mutually exclusive"}
+	}
+
+	line, err := bufio.NewReader(e.Stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		return "", &usageError{"no password on stdin"}
+	}
+	return password, nil
+}
+
+// Defaults to the configured currency, parse with parseCurrency
+func (e *Env) addCurrencyFlag(fs *flag.FlagSet, usage string) *string {
+	return fs.String("currency", string(e.Config.DefaultCurrency), usage+", e.g. SGD")
+}
+
+func parseCurrency(value string) (apiModels.Currency, error) {
+	currency := apiModels.Currency(strings.ToUpper(value))
+	if currency == "" {
+		return "", &usageError{"missing required flag --currency"}
+	}
+	if err := currency.Validate(); err != nil {
+		return "", &usageError{fmt.Sprintf("--currency: %s %q", err, value)}
+	}
+	return currency, nil
+}
+
+func parseMoney(name, value string, currency apiModels.Currency) (apiModels.Money, error) {
+	money, err := apiModels.ParseMoney(value, currency)
+	if err != nil {
+		return apiModels.Money{}, &usageError{fmt.Sprintf("--%s: %s", name, err)}
+	}
+	return money, nil
+}
+
+func (e *Env) print(data interface{}) {
+	pp := services.PrettyPrinter{Out: e.Stdout}
+	pp.PrintPlain(data)
+}
@@ -0,0 +1,106 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/chiahsoon/cz4013-client/config"
+	"github.com/chiahsoon/cz4013-client/experiment"
+	"github.com/chiahsoon/cz4013-client/server"
+	"github.com/chiahsoon/cz4013-client/transport"
+)
+
+func runExperiment(ctx context.Context, e *Env, args []string) error {
+	fs := e.newFlagSet("experiment")
+	ops := fs.Int("ops", 20, "Operations per workload and semantic")
+	amount := fs.String("amount", "1", "Amount withdrawn by each operation")
+	initialBalance := fs.String("initial-balance", "1000", "Balance of each experiment account")
+	currency := e.addCurrencyFlag(fs, "Currency of each experiment account")
+	semantics := fs.String("semantics", "maybe,at-least-once,at-most-once", "Comma separated invocation semantics to compare")
+	csvPath := fs.String("csv", "", "Also write the results as CSV to this file")
+	local := fs.Bool("local", false, "Run against an in-process reference server instead of -host/-port")
+	if err := e.parse(fs, args); err != nil {
+		return err
+	}
+
+	currencyCode, err := parseCurrency(*currency)
+	if err != nil {
+		return err
+	}
+	cfg := experiment.Config{
+		Simulation: e.Config.Simulation,
+		Retry:      e.Config.Retry,
+		Secure:     e.Config.Secure,
+		Operations: *ops,
+	}
+	if cfg.Amount, err = parseMoney("amount", *amount, currencyCode); err != nil {
+		return err
+	}
+	if cfg.InitialBalance, err = parseMoney("initial-balance", *initialBalance, currencyCode); err != nil {
+		return err
+	}
+	for _, s := range strings.Split(*semantics, ",") {
+		semantic := config.InvocationSemantic(strings.TrimSpace(s))
+		if err := semantic.Validate(); err != nil {
+			return &usageError{fmt.Sprintf("%s: %q", err, s)}
+		}
+		cfg.Semantics = append(cfg.Semantics, semantic)
+	}
+
+	if *ops <= 0 || !cfg.Amount.IsPositive() {
+		return &usageError{"--ops and --amount must be positive"}
+	}
+
+	if !cfg.Simulation.Enabled() {
+		fmt.Fprintln(e.Stderr, "warning: no loss is simulated, pass e.g. -drop-request/-drop-reply before the command")
+	}
+
+	dial := e.Dial
+	if *local {
+		addr := "127.0.0.1:0"
+		if e.Config.Transport == config.Unix {
+			addr = filepath.Join(os.TempDir(), fmt.Sprintf("cz4013-experiment-%d.sock", os.Getpid()))
+			defer os.Remove(addr)
+		}
+		pc, err := transport.Listen(e.Config.Transport, addr)
+		if err != nil {
+			return err
+		}
+		defer pc.Close()
+
+		srv := server.NewServer(server.NewBank())
+		if cfg.Secure.Enabled {
+			if srv.Key, err = transport.GenerateKey(); err != nil {
+				return err
+			}
+			cfg.Secure.ServerKey = srv.Key.PublicKey().Bytes()
+		}
+		go srv.Serve(pc)
+		dial = func() (net.Conn, error) { return transport.Dial(ctx, e.Config.Transport, pc.LocalAddr().String()) }
+	}
+
+	runner := experiment.Runner{Config: cfg, Dial: dial}
+	results, err := runner.Run(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := experiment.WriteTable(e.Stdout, results); err != nil {
+		return err
+	}
+
+	if *csvPath == "" {
+		return nil
+	}
+
+	f, err := os.Create(*csvPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return experiment.WriteCSV(f, results)
+}
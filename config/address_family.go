@@ -0,0 +1,70 @@
+package config
+
+import (
+	"errors"
+	"net"
+	"strings"
+)
+
+// Which addresses of the server to use, any by default
+type AddressFamily string
+
+const (
+	AnyFamily AddressFamily = ""
+	IPv4      AddressFamily = "ipv4"
+	IPv6      AddressFamily = "ipv6"
+)
+
+func (f AddressFamily) Validate() error {
+	switch f {
+	case AnyFamily, IPv4, IPv6:
+		return nil
+	default:
+		return errors.New("invalid address family")
+	}
+}
+
+// Network restricts a network name such as "udp" or "tcp" to the family, e.g. "udp6"
+func (f AddressFamily) Network(network string) string {
+	switch f {
+	case IPv4:
+		return network + "4"
+	case IPv6:
+		return network + "6"
+	default:
+		return network
+	}
+}
+
+// Allows reports whether ip belongs to the family
+func (f AddressFamily) Allows(ip net.IP) bool {
+	switch f {
+	case IPv4:
+		return ip.To4() != nil
+	case IPv6:
+		return ip.To4() == nil
+	default:
+		return true
+	}
+}
+
+// ParseHost splits a host that may include a port, such as "example.com", "::1", "[::1]" or "[::1]:5000".
+// port is returned unchanged if host has none.
+func ParseHost(host string, port string) (string, string, error) {
+	switch {
+	case strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]"):
+		return host[1 : len(host)-1], port, nil
+	case strings.HasPrefix(host, "["), strings.Count(host, ":") == 1:
+		h, p, err := net.SplitHostPort(host)
+		if err != nil {
+			return "", "", err
+		}
+		if p == "" {
+			p = port
+		}
+		return h, p, nil
+	default:
+		// IPv6 literals without brackets cannot include a port
+		return host, port, nil
+	}
+}
@@ -1,14 +1,25 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"net"
+
+	apiModels "github.com/chiahsoon/cz4013-client/api/models"
 )
 
 type Config struct {
 	InvocationSemantic
-	Host string
-	Port string
+	Host            string
+	Port            string
+	Family          AddressFamily // Restricts the server's addresses to one family, any if empty
+	Transport       Transport
+	Socket          string             // Path of the server's socket with the unix transport, which ignores Host and Port
+	DefaultCurrency apiModels.Currency // Preselected in prompts and used when --currency is omitted, none if empty
+	Window          int                // Requests allowed to be outstanding at once
+	Retry           RetryConfig
+	Simulation      LossSimulation
+	Secure          SecureChannel
 }
 
 func (cfg *Config) Validate() error {
@@ -16,11 +27,55 @@ func (cfg *Config) Validate() error {
 		return err
 	}
 
-	addr := fmt.Sprintf("%s:%s", cfg.Host, cfg.Port)
-	_, err := net.ResolveUDPAddr("udp4", addr)
+	if cfg.DefaultCurrency != "" {
+		if err := cfg.DefaultCurrency.Validate(); err != nil {
+			return fmt.Errorf("%s %q", err, cfg.DefaultCurrency)
+		}
+	}
+
+	if cfg.Window <= 0 {
+		return errors.New("window must be positive")
+	}
+
+	if err := cfg.Retry.Validate(); err != nil {
+		return err
+	}
+
+	if err := cfg.Simulation.Validate(); err != nil {
+		return err
+	}
+
+	if err := cfg.Secure.Validate(); err != nil {
+		return err
+	}
+
+	if err := cfg.Transport.Validate(); err != nil {
+		return err
+	}
+
+	if err := cfg.Family.Validate(); err != nil {
+		return err
+	}
+
+	if cfg.Transport == Unix {
+		if cfg.Socket == "" {
+			return errors.New("the unix transport requires a socket path")
+		}
+		if cfg.Family != AnyFamily {
+			return errors.New("an address family cannot be chosen for the unix transport")
+		}
+		return nil
+	}
+
+	_, err := net.ResolveUDPAddr(cfg.Family.Network("udp"), cfg.Addr())
 	if err != nil {
 		return err
 	}
 
 	return nil
 }
+
+// Addr is the host and port of the server, with IPv6 literals in brackets
+func (cfg *Config) Addr() string {
+	return net.JoinHostPort(cfg.Host, cfg.Port)
+}
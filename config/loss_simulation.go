@@ -0,0 +1,36 @@
+package config
+
+import (
+	"errors"
+	"time"
+)
+
+// Simulated network conditions applied to the server connection
+type LossSimulation struct {
+	DropRequest float64       // Probability of dropping an outgoing request
+	DropReply   float64       // Probability of dropping an incoming reply
+	Duplicate   float64       // Probability of delivering a datagram twice
+	Reorder     float64       // Probability of holding a datagram back until the next one
+	Corrupt     float64       // Probability of flipping a bit in a datagram
+	Delay       time.Duration // Fixed delay added to every datagram
+	Jitter      time.Duration // Delay varies uniformly within +/- Jitter
+	Seed        int64
+}
+
+func (s LossSimulation) Enabled() bool {
+	return s.DropRequest > 0 || s.DropReply > 0 || s.Duplicate > 0 || s.Reorder > 0 || s.Corrupt > 0 || s.Delay > 0 || s.Jitter > 0
+}
+
+func (s LossSimulation) Validate() error {
+	for _, p := range []float64{s.DropRequest, s.DropReply, s.Duplicate, s.Reorder, s.Corrupt} {
+		if p < 0 || p > 1 {
+			return errors.New("simulated probabilities must be between 0 and 1")
+		}
+	}
+
+	if s.Delay < 0 || s.Jitter < 0 {
+		return errors.New("simulated delay and jitter cannot be negative")
+	}
+
+	return nil
+}
@@ -0,0 +1,55 @@
+package config
+
+import (
+	"errors"
+	"time"
+)
+
+type Backoff string
+
+const (
+	FixedBackoff       Backoff = "fixed"
+	ExponentialBackoff Backoff = "exponential"
+	JitterBackoff      Backoff = "jitter"
+)
+
+func (b Backoff) Validate() error {
+	switch b {
+	case FixedBackoff, ExponentialBackoff, JitterBackoff:
+		return nil
+	default:
+		return errors.New("invalid backoff")
+	}
+}
+
+type RetryConfig struct {
+	Backoff
+	Timeout    time.Duration // Time to wait for the first reply
+	MaxTimeout time.Duration // Upper bound on the time to wait for any single reply
+	MaxRetries int           // Retransmissions after the first attempt, -1 to retry until the deadline
+	Deadline   time.Duration // Upper bound on the whole call including retries, 0 for none
+}
+
+func (cfg RetryConfig) Validate() error {
+	if err := cfg.Backoff.Validate(); err != nil {
+		return err
+	}
+
+	if cfg.Timeout <= 0 {
+		return errors.New("timeout must be positive")
+	}
+
+	if cfg.MaxTimeout < cfg.Timeout {
+		return errors.New("max timeout cannot be less than the timeout")
+	}
+
+	if cfg.MaxRetries < -1 {
+		return errors.New("max retries must be -1 or more")
+	}
+
+	if cfg.Deadline < 0 {
+		return errors.New("deadline cannot be negative")
+	}
+
+	return nil
+}
@@ -0,0 +1,23 @@
+package config
+
+import "errors"
+
+// Seals every datagram to the server, see transport.SecureConn
+type SecureChannel struct {
+	Enabled   bool
+	ServerKey []byte // Pinned static key of the server, any key is accepted if nil
+}
+
+func (s SecureChannel) Validate() error {
+	if s.ServerKey == nil {
+		return nil
+	}
+
+	if !s.Enabled {
+		return errors.New("a pinned server key requires the secure channel")
+	}
+	if len(s.ServerKey) != 32 {
+		return errors.New("the pinned server key must be 32 bytes")
+	}
+	return nil
+}
@@ -0,0 +1,21 @@
+package config
+
+import "errors"
+
+// How messages are carried to the server
+type Transport string
+
+const (
+	UDP  Transport = "udp"
+	TCP  Transport = "tcp"  // Messages are framed by their length on a single stream
+	Unix Transport = "unix" // Unix datagram socket, for servers on the same host
+)
+
+func (t Transport) Validate() error {
+	switch t {
+	case UDP, TCP, Unix:
+		return nil
+	default:
+		return errors.New("invalid transport")
+	}
+}
@@ -0,0 +1,276 @@
+package experiment
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	apiModels "github.com/chiahsoon/cz4013-client/api/models"
+	"github.com/chiahsoon/cz4013-client/client"
+	"github.com/chiahsoon/cz4013-client/config"
+	"github.com/chiahsoon/cz4013-client/services"
+	"github.com/chiahsoon/cz4013-client/transport"
+)
+
+const (
+	WithdrawWorkload = "withdraw"
+	BalanceWorkload  = "balance"
+)
+
+// Config describes one run of the experiment
+type Config struct {
+	Semantics      []config.InvocationSemantic
+	Simulation     config.LossSimulation // Applied to the connection under test only
+	Retry          config.RetryConfig
+	Secure         config.SecureChannel
+	Operations     int             // Per workload and semantic
+	Amount         apiModels.Money // Withdrawn by each operation
+	InitialBalance apiModels.Money // Also sets the account currency
+}
+
+// Result of one workload under one invocation semantic
+type Result struct {
+	Semantic        config.InvocationSemantic
+	Workload        string
+	Operations      int
+	Failed          int             // Operations that returned an error to the caller
+	Unknown         int             // Withdraw: failed operations whose request reached the server, e.g. when only the reply was lost
+	Expected        apiModels.Money // Balance the semantic allows given the requests that reached the server, see expectedBalance
+	Observed        apiModels.Money // Balance read over a lossless connection afterwards
+	Anomalies       int             // Withdraw: executions beyond one per operation that took effect, Balance: reads that disagreed with Expected
+	Retransmissions int64
+	StaleReplies    int64
+	CorruptReplies  int64
+	Latencies       []time.Duration
+}
+
+func (r Result) Consistent() bool {
+	return r.Anomalies == 0 && r.Expected == r.Observed
+}
+
+// Percentile of successful operation latencies using the nearest-rank method
+func (r Result) Percentile(p float64) time.Duration {
+	if len(r.Latencies) == 0 {
+		return 0
+	}
+
+	sorted := append([]time.Duration{}, r.Latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	rank := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	return sorted[rank]
+}
+
+// Runner opens a fresh account per semantic and drives both workloads against it
+type Runner struct {
+	Config Config
+	Dial   func() (net.Conn, error)
+}
+
+func (r *Runner) Run(ctx context.Context) ([]Result, error) {
+	reliableConn, err := r.Dial()
+	if err != nil {
+		return nil, err
+	}
+	defer reliableConn.Close()
+
+	layered, _, err := transport.LayerClientConn(reliableConn, r.Config.Secure)
+	if err != nil {
+		return nil, err
+	}
+	reliable := client.NewClient(layered, &services.ConnectionService{
+		InvocationSemantic: config.AtMostOnce,
+		RetryPolicy:        services.NewRetryPolicy(r.Config.Retry),
+		MaxRetryCount:      -1,
+		Deadline:           r.Config.Retry.Deadline,
+	})
+
+	results := []Result{}
+	for _, semantic := range r.Config.Semantics {
+		semanticResults, err := r.runSemantic(ctx, reliable, semantic)
+		if err != nil {
+			return results, fmt.Errorf("%s: %w", semantic, err)
+		}
+		results = append(results, semanticResults...)
+	}
+	return results, nil
+}
+
+func (r *Runner) runSemantic(ctx context.Context, reliable *client.Client, semantic config.InvocationSemantic) ([]Result, error) {
+	name := fmt.Sprintf("experiment-%s", semantic)
+	password := "experiment"
+	accountNumber, err := r.openAccount(ctx, reliable, name, password)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := r.Dial()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	connSvc := &services.ConnectionService{
+		InvocationSemantic: semantic,
+		RetryPolicy:        services.NewRetryPolicy(r.Config.Retry),
+		MaxRetryCount:      r.Config.Retry.MaxRetries,
+		Deadline:           r.Config.Retry.Deadline,
+	}
+	faults := transport.NewLossyConn(conn, r.Config.Simulation)
+	lossyConn, _, err := transport.LayerClientConn(faults, r.Config.Secure)
+	if err != nil {
+		return nil, err
+	}
+	lossy := client.NewClient(lossyConn, connSvc)
+	getBalance := func(c *client.Client) (apiModels.Money, error) {
+		resp, err := c.GetBalance(ctx, apiModels.GetBalanceReq{
+			AccountNumber: accountNumber, Name: name, Password: password, Currency: r.Config.InitialBalance.Currency,
+		})
+		return resp.Balance, err
+	}
+
+	// Non-idempotent workload, with the requests of operation i labelled i+1
+	withdraw := Result{Semantic: semantic, Workload: WithdrawWorkload, Operations: r.Config.Operations}
+	succeeded := 0
+	failed := map[int]bool{}
+	before := connSvc.Stats()
+	for i := 0; i < r.Config.Operations; i++ {
+		faults.Label(i + 1)
+		start := time.Now()
+		_, err := lossy.Withdraw(ctx, apiModels.UpdateBalanceReq{
+			AccountNumber: accountNumber, Name: name, Password: password, Amount: r.Config.Amount,
+		})
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err != nil {
+			withdraw.Failed++
+			failed[i+1] = true
+			continue
+		}
+		succeeded++
+		withdraw.Latencies = append(withdraw.Latencies, time.Since(start))
+	}
+	faults.Label(0)
+	r.recordStats(&withdraw, before, connSvc.Stats())
+
+	// Let requests still held back or delayed reach the server before its state is read
+	if err := faults.Flush(); err != nil {
+		return nil, err
+	}
+	if err := sleepContext(ctx, r.Config.Simulation.Delay+r.Config.Simulation.Jitter); err != nil {
+		return nil, err
+	}
+
+	delivered := faults.Delivered()
+	for op := range failed {
+		if delivered[op] > 0 {
+			withdraw.Unknown++
+		}
+	}
+	withdraw.Expected = r.expectedBalance(semantic, delivered)
+	if withdraw.Observed, err = getBalance(reliable); err != nil {
+		return nil, err
+	}
+	if r.Config.Amount.IsPositive() {
+		executed := int((r.Config.InitialBalance.Units - withdraw.Observed.Units) / r.Config.Amount.Units)
+		if surplus := executed - succeeded - withdraw.Unknown; surplus > 0 {
+			withdraw.Anomalies = surplus
+		}
+	}
+
+	// Idempotent workload, which must leave the balance as the withdrawals did
+	balance := Result{Semantic: semantic, Workload: BalanceWorkload, Operations: r.Config.Operations}
+	balance.Expected = withdraw.Expected
+	before = connSvc.Stats()
+	for i := 0; i < r.Config.Operations; i++ {
+		start := time.Now()
+		read, err := getBalance(lossy)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err != nil {
+			balance.Failed++
+			continue
+		}
+		balance.Latencies = append(balance.Latencies, time.Since(start))
+		if read != balance.Expected {
+			balance.Anomalies++
+		}
+	}
+	r.recordStats(&balance, before, connSvc.Stats())
+
+	if balance.Observed, err = getBalance(reliable); err != nil {
+		return nil, err
+	}
+	return []Result{withdraw, balance}, nil
+}
+
+// Balance after the withdrawals, derived only from the requests that reached the server intact (labelled by
+// operation) and the semantic. At-most-once executes each operation at most once however many copies of its
+// request arrive, the other semantics execute every copy. Withdrawals beyond the initial balance are refused.
+func (r *Runner) expectedBalance(semantic config.InvocationSemantic, delivered map[int]int) apiModels.Money {
+	initial := r.Config.InitialBalance
+	if !r.Config.Amount.IsPositive() {
+		return initial
+	}
+
+	executions := 0
+	for op := 1; op <= r.Config.Operations; op++ {
+		n := delivered[op]
+		if semantic == config.AtMostOnce && n > 1 {
+			n = 1
+		}
+		executions += n
+	}
+	if affordable := int(initial.Units / r.Config.Amount.Units); executions > affordable {
+		executions = affordable
+	}
+	return apiModels.NewMoney(initial.Units-int64(executions)*r.Config.Amount.Units, initial.Currency)
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *Runner) openAccount(ctx context.Context, reliable *client.Client, name, password string) (int, error) {
+	resp, err := reliable.OpenAccount(ctx, apiModels.OpenAccountReq{
+		Name: name, Password: password, InitialBalance: r.Config.InitialBalance,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	// The account number is only reported as part of the message
+	fields := strings.Fields(resp.Message)
+	var accountNumber int
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("unexpected open account response %q", resp.Message)
+	}
+	if _, err := fmt.Sscan(fields[len(fields)-1], &accountNumber); err != nil {
+		return 0, fmt.Errorf("unexpected open account response %q", resp.Message)
+	}
+	return accountNumber, nil
+}
+
+func (r *Runner) recordStats(result *Result, before, after services.ConnectionStats) {
+	result.Retransmissions = after.Retransmissions - before.Retransmissions
+	result.StaleReplies = after.StaleReplies - before.StaleReplies
+	result.CorruptReplies = after.CorruptReplies - before.CorruptReplies
+}
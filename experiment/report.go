@@ -0,0 +1,78 @@
+package experiment
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+var columns = []string{
+	"semantic", "workload", "operations", "failed", "unknown", "expected", "observed", "consistent",
+	"anomalies", "retransmissions", "stale_replies", "corrupt_replies", "p50", "p90", "p99",
+}
+
+// Latencies are written as milliseconds in CSV so that they can be plotted directly
+var csvColumns = append(append([]string{}, columns[:len(columns)-3]...), "p50_ms", "p90_ms", "p99_ms")
+
+func formatDuration(d time.Duration) string {
+	return d.Round(time.Microsecond).String()
+}
+
+func formatMillis(d time.Duration) string {
+	return strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', 3, 64)
+}
+
+func (r Result) row(format func(time.Duration) string) []string {
+	return []string{
+		string(r.Semantic),
+		r.Workload,
+		strconv.Itoa(r.Operations),
+		strconv.Itoa(r.Failed),
+		strconv.Itoa(r.Unknown),
+		r.Expected.Decimal(),
+		r.Observed.Decimal(),
+		strconv.FormatBool(r.Consistent()),
+		strconv.Itoa(r.Anomalies),
+		strconv.FormatInt(r.Retransmissions, 10),
+		strconv.FormatInt(r.StaleReplies, 10),
+		strconv.FormatInt(r.CorruptReplies, 10),
+		format(r.Percentile(50)),
+		format(r.Percentile(90)),
+		format(r.Percentile(99)),
+	}
+}
+
+func WriteTable(w io.Writer, results []Result) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, col := range columns {
+		fmt.Fprintf(tw, "%s\t", col)
+	}
+	fmt.Fprintln(tw)
+
+	for _, result := range results {
+		for _, cell := range result.row(formatDuration) {
+			fmt.Fprintf(tw, "%s\t", cell)
+		}
+		fmt.Fprintln(tw)
+	}
+	return tw.Flush()
+}
+
+func WriteCSV(w io.Writer, results []Result) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvColumns); err != nil {
+		return err
+	}
+
+	for _, result := range results {
+		if err := cw.Write(result.row(formatMillis)); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
@@ -1,5 +1,18 @@
 module github.com/chiahsoon/cz4013-client
 
-go 1.15
+go 1.20
 
-require github.com/AlecAivazis/survey/v2 v2.3.2 // indirect
+require (
+	github.com/AlecAivazis/survey/v2 v2.3.2
+	golang.org/x/crypto v0.21.0
+)
+
+require (
+	github.com/kballard/go-shellquote v0.0.0-20180428030007-95032a82bc51 // indirect
+	github.com/mattn/go-colorable v0.1.2 // indirect
+	github.com/mattn/go-isatty v0.0.8 // indirect
+	github.com/mgutz/ansi v0.0.0-20170206155736-9520e82c474b // indirect
+	golang.org/x/sys v0.18.0 // indirect
+	golang.org/x/term v0.18.0 // indirect
+	golang.org/x/text v0.14.0 // indirect
+)
@@ -1,50 +1,31 @@
 package handlers
 
 import (
-	"net"
+	"context"
 
-	"github.com/AlecAivazis/survey/v2"
-	"github.com/chiahsoon/cz4013-client/api"
-	"github.com/chiahsoon/cz4013-client/api/codec"
 	apiModels "github.com/chiahsoon/cz4013-client/api/models"
+	"github.com/chiahsoon/cz4013-client/client"
 	"github.com/chiahsoon/cz4013-client/models"
 	"github.com/chiahsoon/cz4013-client/services"
 )
 
-func HandleCloseAccount(action models.UserSelectedAction, conn *net.UDPConn) {
+func HandleCloseAccount(ctx context.Context, action models.UserSelectedAction, c *client.Client) {
 	if action != models.CloseAccountAction {
 		return
 	}
 
-	req := api.NewRequest()
-	req.Method = string(api.CloseAccountAPI)
 	input := apiModels.CloseAccountReq{}
-
-	err := survey.Ask(services.UI.GetSubPromptsForAction()[action], &input)
+	err := askWithSession(c, services.UI.GetSubPromptsForAction(nil)[action], &input)
 	if err != nil {
 		services.PP.PrintError(err.Error(), "", "")
 		return
 	}
-	req.Data = input
 
-	resp := api.Response{}
-	err = services.ConnSvc.Fetch(conn, req, &resp)
+	respData, err := c.CloseAccount(ctx, input)
 	if err != nil {
 		services.PP.PrintError(err.Error(), "", "")
 		return
 	}
 
-	if resp.HasError() {
-		services.PP.PrintError(resp.ErrMsg, "", "")
-	} else {
-		// Unmarshall and print rest of data
-		c := codec.Codec{}
-		var respData apiModels.CloseAccountResp
-		if err := c.DecodeAsInterface(resp.Data, &respData); err != nil {
-			services.PP.PrintError(err.Error(), "", "")
-			return
-		}
-
-		services.PP.Print(respData, "- Response -", "")
-	}
+	services.PP.Print(respData, "- Response -", "")
 }
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/AlecAivazis/survey/v2"
+	apiModels "github.com/chiahsoon/cz4013-client/api/models"
+	"github.com/chiahsoon/cz4013-client/client"
+	"github.com/chiahsoon/cz4013-client/models"
+	"github.com/chiahsoon/cz4013-client/services"
+)
+
+func HandleLogin(ctx context.Context, action models.UserSelectedAction, c *client.Client) {
+	if action != models.LoginAction {
+		return
+	}
+
+	input := apiModels.LoginReq{}
+	err := survey.Ask(services.UI.GetSubPromptsForAction(nil)[action], &input)
+	if err != nil {
+		services.PP.PrintError(err.Error(), "", "")
+		return
+	}
+
+	session, err := c.Login(ctx, input)
+	if err != nil {
+		services.PP.PrintError(err.Error(), "", "")
+		return
+	}
+
+	services.PP.PrintMessage(fmt.Sprintf("Logged in to account %d until %s", session.AccountNumber, session.ExpiresAt.Format("15:04:05")), "", "")
+}
+
+func HandleLogout(ctx context.Context, action models.UserSelectedAction, c *client.Client) {
+	if action != models.LogoutAction {
+		return
+	}
+
+	if err := c.Logout(ctx); err != nil {
+		services.PP.PrintError(err.Error(), "", "")
+		return
+	}
+
+	services.PP.PrintMessage("Logged out", "", "")
+}
+
+// Asks the questions for an action, leaving out the account number, name and password when logged in
+func askWithSession(c *client.Client, qns []*survey.Question, answers interface{}) error {
+	if _, ok := c.Session(); ok {
+		qns = services.UI.WithoutCredentials(qns)
+	}
+	return survey.Ask(qns, answers)
+}
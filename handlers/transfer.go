@@ -1,50 +1,87 @@
 package handlers
 
 import (
-	"net"
+	"context"
+	"fmt"
 
 	"github.com/AlecAivazis/survey/v2"
-	"github.com/chiahsoon/cz4013-client/api"
-	"github.com/chiahsoon/cz4013-client/api/codec"
 	apiModels "github.com/chiahsoon/cz4013-client/api/models"
+	"github.com/chiahsoon/cz4013-client/client"
 	"github.com/chiahsoon/cz4013-client/models"
 	"github.com/chiahsoon/cz4013-client/services"
 )
 
-func HandleTransfer(action models.UserSelectedAction, conn *net.UDPConn) {
+func HandleTransfer(ctx context.Context, action models.UserSelectedAction, c *client.Client) {
 	if action != models.TransferAction {
 		return
 	}
 
-	req := api.NewRequest()
-	req.Method = string(api.TransferAPI)
-	input := apiModels.TransferReq{}
-
-	err := survey.Ask(services.UI.GetSubPromptsForAction()[action], &input)
+	answers := models.TransferAnswers{}
+	err := askWithSession(c, services.UI.GetSubPromptsForAction(answers.SelectedCurrency)[action], &answers)
 	if err != nil {
 		services.PP.PrintError(err.Error(), "", "")
 		return
 	}
-	req.Data = input
 
-	resp := api.Response{}
-	err = services.ConnSvc.Fetch(conn, req, &resp)
+	input, err := answers.Request()
 	if err != nil {
 		services.PP.PrintError(err.Error(), "", "")
 		return
 	}
 
-	if resp.HasError() {
-		services.PP.PrintError(resp.ErrMsg, "", "")
-	} else {
-		// Unmarshall and print rest of data
-		c := codec.Codec{}
-		var respData apiModels.TransferResp
-		if err := c.DecodeAsInterface(resp.Data, &respData); err != nil {
+	if session, ok := c.Session(); ok {
+		input.AccountNumber = session.AccountNumber
+	}
+
+	memo := ""
+	if err := survey.AskOne(services.UI.GetMemoPrompt(), &memo); err != nil {
+		services.PP.PrintError(err.Error(), "", "")
+		return
+	}
+	if memo != "" {
+		input.Memo = &memo
+	}
+
+	services.PP.Print(models.NewTransferSummary(input), "- Transfer -", "")
+	for previewed := false; ; previewed = true {
+		choice := ""
+		if err := survey.AskOne(services.UI.GetTransferConfirmPrompt(previewed), &choice); err != nil {
 			services.PP.PrintError(err.Error(), "", "")
 			return
 		}
+		if choice == services.TransferSend {
+			break
+		}
+		if choice != services.TransferPreview {
+			services.PP.PrintMessage("Transfer cancelled", "", "")
+			return
+		}
+		services.PP.PrintMessage(quoteTransfer(ctx, c, input), "", "")
+	}
+
+	respData, err := c.Transfer(ctx, input)
+	if err != nil {
+		services.PP.PrintError(err.Error(), "", "")
+		return
+	}
+
+	services.PP.Print(respData, "- Response -", "")
+}
+
+// Describes what the destination receives, or why that is unknown. Servers without exchange rates reject the
+// quote, in which case the transfer can still be confirmed without a preview. Only the server knows the
+// destination's currency, so the quote is a request of its own and is only sent when the user asks for it.
+func quoteTransfer(ctx context.Context, c *client.Client, input apiModels.TransferReq) string {
+	quote, err := c.QuoteTransfer(ctx, apiModels.QuoteTransferReq{
+		AccountNumber: input.AccountNumber, Name: input.Name, Password: input.Password,
+		Amount: input.Amount, DestAccountNumber: input.DestAccountNumber,
+	})
+	if err != nil {
+		return fmt.Sprintf("No preview available: %s", err)
+	}
 
-		services.PP.Print(respData, "- Response -", "")
+	if quote.Received.Currency == input.Amount.Currency {
+		return fmt.Sprintf("Recipient receives %s", quote.Received)
 	}
+	return fmt.Sprintf("Recipient receives %s (1 %s = %s %s)", quote.Received, input.Amount.Currency, quote.Rate, quote.Received.Currency)
 }
@@ -1,51 +1,36 @@
 package handlers
 
 import (
-	"net"
+	"context"
 
-	"github.com/AlecAivazis/survey/v2"
-	"github.com/chiahsoon/cz4013-client/api"
-	"github.com/chiahsoon/cz4013-client/api/codec"
-	apiModels "github.com/chiahsoon/cz4013-client/api/models"
+	"github.com/chiahsoon/cz4013-client/client"
 	"github.com/chiahsoon/cz4013-client/models"
 	"github.com/chiahsoon/cz4013-client/services"
 )
 
-func HandleWithdraw(action models.UserSelectedAction, conn *net.UDPConn) {
+func HandleWithdraw(ctx context.Context, action models.UserSelectedAction, c *client.Client) {
 	if action != models.WithdrawAction {
 		return
 	}
 
-	req := api.NewRequest()
-	req.Method = string(api.UpdateBalanceAPI)
-	input := apiModels.UpdateBalanceReq{}
-
-	err := survey.Ask(services.UI.GetSubPromptsForAction()[action], &input)
+	answers := models.UpdateBalanceAnswers{}
+	err := askWithSession(c, services.UI.GetSubPromptsForAction(answers.SelectedCurrency)[action], &answers)
 	if err != nil {
 		services.PP.PrintError(err.Error(), "", "")
 		return
 	}
-	input.Amount *= -1
-	req.Data = input
 
-	resp := api.Response{}
-	err = services.ConnSvc.Fetch(conn, req, &resp)
+	input, err := answers.Request()
 	if err != nil {
 		services.PP.PrintError(err.Error(), "", "")
 		return
 	}
 
-	if resp.HasError() {
-		services.PP.PrintError(resp.ErrMsg, "", "")
-	} else {
-		// Unmarshall and print rest of data
-		c := codec.Codec{}
-		var respData apiModels.UpdateBalanceResp
-		if err := c.DecodeAsInterface(resp.Data, &respData); err != nil {
-			services.PP.PrintError(err.Error(), "", "")
-			return
-		}
-
-		services.PP.Print(respData, "- Response -", "")
+	respData, err := c.Withdraw(ctx, input)
+	if err != nil {
+		services.PP.PrintError(err.Error(), "", "")
+		return
 	}
+
+	services.PP.Print(respData, "- Response -", "")
 }
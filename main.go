@@ -1,59 +1,212 @@
 package main
 
 import (
+	"context"
+	"encoding/hex"
 	"flag"
 	"fmt"
 	"net"
+	"os"
+	"os/signal"
+	"strings"
 	"time"
 
 	"github.com/AlecAivazis/survey/v2"
+	apiModels "github.com/chiahsoon/cz4013-client/api/models"
+	"github.com/chiahsoon/cz4013-client/client"
+	"github.com/chiahsoon/cz4013-client/commands"
 	"github.com/chiahsoon/cz4013-client/config"
 	"github.com/chiahsoon/cz4013-client/handlers"
 	"github.com/chiahsoon/cz4013-client/models"
 	"github.com/chiahsoon/cz4013-client/services"
+	"github.com/chiahsoon/cz4013-client/transport"
 )
 
-func connect(host string, port string) *net.UDPConn {
-	addr := fmt.Sprintf("%s:%s", host, port)
-	s, err := net.ResolveUDPAddr("udp4", addr)
+// Dials the server and layers the connection as configured, including the loss simulation
+func connect(host string, port string) (net.Conn, error) {
+	conn, err := dial(host, port)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
-	conn, err := net.DialUDP("udp4", nil, s)
+	if config.Global.Simulation.Enabled() {
+		if config.Global.Simulation.Seed == 0 {
+			config.Global.Simulation.Seed = time.Now().UnixNano()
+		}
+		fmt.Fprintf(os.Stderr, "Simulating network conditions with seed %d\n", config.Global.Simulation.Seed)
+		lossy := transport.NewLossyConn(conn, config.Global.Simulation)
+		lossy.OnSendError = func(err error) {
+			fmt.Fprintf(os.Stderr, "delayed request not sent: %s\n", err)
+		}
+		conn = lossy
+	}
+	layered, presentedKey, err := transport.LayerClientConn(conn, config.Global.Secure)
 	if err != nil {
-		panic(err)
+		conn.Close()
+		return nil, err
+	}
+	if config.Global.Secure.Enabled && config.Global.Secure.ServerKey == nil {
+		fmt.Fprintf(os.Stderr, "Secure channel established with server key %s, pass it as -server-key to pin it\n", transport.FormatKey(presentedKey))
+	}
+	return layered, nil
+}
+
+// Picks the first of the server's addresses that answers, see transport.Dialer
+func dial(host string, port string) (net.Conn, error) {
+	if config.Global.Transport == config.Unix {
+		return transport.DialUnix(config.Global.Socket)
+	}
+
+	d := transport.Dialer{
+		Transport: config.Global.Transport,
+		Family:    config.Global.Family,
+		Probe:     probe,
+		OnNoAnswer: func(err error) {
+			fmt.Fprintf(os.Stderr, "warning: no address of %s answered (%s), using the first\n", host, err)
+		},
 	}
+	return d.Dial(context.Background(), host, port)
+}
 
-	return conn
+// Checks that the server answers on conn through the same layers as the connection proper. With the secure
+// channel the handshake is the probe, and is repeated once the connection is chosen.
+func probe(ctx context.Context, conn net.Conn) error {
+	layered, _, err := transport.LayerClientConn(conn, config.Global.Secure)
+	if err != nil {
+		return err
+	}
+	return services.Ping(ctx, layered, config.Global.Retry.Timeout)
 }
 
 func main() {
-	host := flag.String("host", "localhost", "IP address of the server")
+	host := flag.String("host", "localhost", "Host name or IP address of the server, optionally with a port, e.g. [::1]:5000")
 	port := flag.String("port", "5000", "Port of the server")
+	ipv4 := flag.Bool("4", false, "Only use IPv4 addresses of the server")
+	ipv6 := flag.Bool("6", false, "Only use IPv6 addresses of the server")
+	transportName := flag.String("transport", string(config.UDP), "How requests are carried - udp (Default), tcp, unix")
+	socket := flag.String("socket", transport.DefaultSocketPath(), "Path of the server's socket with -transport unix")
 	semantic := flag.String("semantic", string(config.AtLeastOnce), "Invocation Semantic - at-least-once (Default), at-most-once")
+	currency := flag.String("currency", "SGD", "Default currency of prompts and commands, empty for none")
+	dropRequest := flag.Float64("drop-request", 0, "Probability of dropping an outgoing request (simulation)")
+	dropReply := flag.Float64("drop-reply", 0, "Probability of dropping an incoming reply (simulation)")
+	duplicate := flag.Float64("duplicate", 0, "Probability of delivering a datagram twice (simulation)")
+	reorder := flag.Float64("reorder", 0, "Probability of delivering a datagram after the next one (simulation)")
+	corrupt := flag.Float64("corrupt", 0, "Probability of flipping a bit in a datagram (simulation)")
+	delay := flag.Duration("delay", 0, "Delay added to every datagram, e.g. 200ms (simulation)")
+	jitter := flag.Duration("jitter", 0, "Random variation applied to the delay (simulation)")
+	seed := flag.Int64("seed", 0, "Seed for the simulation, random if 0")
+	timeout := flag.Duration("timeout", time.Second, "Time to wait for the first reply before retransmitting")
+	maxTimeout := flag.Duration("max-timeout", 8*time.Second, "Upper bound on the time to wait for a single reply")
+	maxRetries := flag.Int("max-retries", 5, "Retransmissions per request, -1 to retry until the deadline")
+	backoff := flag.String("backoff", string(config.ExponentialBackoff), "Backoff between retransmissions - fixed, exponential (Default), jitter")
+	secure := flag.Bool("secure", false, "Encrypt and authenticate every datagram, the server must also run with -secure")
+	serverKey := flag.String("server-key", "", "Hex encoded key the server must present with -secure, any key is accepted if empty")
+	deadline := flag.Duration("deadline", 30*time.Second, "Upper bound on a request including retransmissions, 0 for none")
+	window := flag.Int("window", services.DefaultWindow, "Requests allowed to be outstanding at once")
 	flag.Parse()
 
 	// Initialise command line configurations
 	config.Global = &config.Config{}
 	config.Global.InvocationSemantic = config.InvocationSemantic(*semantic)
-	config.Global.Host = *host
-	config.Global.Port = *port
+	var err error
+	config.Global.Host, config.Global.Port, err = config.ParseHost(*host, *port)
+	if err != nil {
+		panic(err)
+	}
+	config.Global.Transport = config.Transport(*transportName)
+	config.Global.Socket = *socket
+	switch {
+	case *ipv4 && *ipv6:
+		panic("-4 and -6 cannot be used together")
+	case *ipv4:
+		config.Global.Family = config.IPv4
+	case *ipv6:
+		config.Global.Family = config.IPv6
+	}
+	config.Global.DefaultCurrency = apiModels.Currency(strings.ToUpper(*currency))
+	config.Global.Window = *window
+	config.Global.Retry = config.RetryConfig{
+		Backoff:    config.Backoff(*backoff),
+		Timeout:    *timeout,
+		MaxTimeout: *maxTimeout,
+		MaxRetries: *maxRetries,
+		Deadline:   *deadline,
+	}
+	config.Global.Simulation = config.LossSimulation{
+		DropRequest: *dropRequest,
+		DropReply:   *dropReply,
+		Duplicate:   *duplicate,
+		Reorder:     *reorder,
+		Corrupt:     *corrupt,
+		Delay:       *delay,
+		Jitter:      *jitter,
+		Seed:        *seed,
+	}
+	config.Global.Secure.Enabled = *secure
+	if *serverKey != "" {
+		key, err := hex.DecodeString(*serverKey)
+		if err != nil {
+			panic(fmt.Errorf("invalid server key: %w", err))
+		}
+		config.Global.Secure.ServerKey = key
+	}
 	if err := config.Global.Validate(); err != nil {
 		panic(err)
 	}
 
-	// Initialise server connection
-	conn := connect(config.Global.Host, config.Global.Port)
-	defer conn.Close()
-
 	// Initialise services
 	services.PP = &services.PrettyPrinter{}
-	services.UI = &services.UIService{}
+	services.UI = &services.UIService{DefaultCurrency: config.Global.DefaultCurrency}
 	services.ConnSvc = &services.ConnectionService{}
 	services.ConnSvc.InvocationSemantic = config.Global.InvocationSemantic
-	services.ConnSvc.TimeoutInterval = time.Duration(1) * time.Second
-	services.ConnSvc.MaxRetryCount = -1
+	services.ConnSvc.RetryPolicy = services.NewRetryPolicy(config.Global.Retry)
+	services.ConnSvc.MaxRetryCount = config.Global.Retry.MaxRetries
+	services.ConnSvc.Deadline = config.Global.Retry.Deadline
+	services.ConnSvc.Window = config.Global.Window
+	services.ConnSvc.OnRetry = func(attempt int, err error) {
+		services.PP.PrintError(err.Error(), "", fmt.Sprintf("Retransmitting (attempt %d) ...", attempt+1))
+	}
+
+	// Run a single subcommand non-interactively if one is given. The server connection is only set up once a
+	// command needs it, as some such as experiment --local start their own server.
+	if flag.NArg() > 0 {
+		services.ConnSvc.OnRetry = func(attempt int, err error) {
+			fmt.Fprintf(os.Stderr, "attempt %d failed: %s\n", attempt, err)
+		}
+		var conn net.Conn
+		env := &commands.Env{
+			Connect: func() (*client.Client, error) {
+				var err error
+				if conn, err = connect(config.Global.Host, config.Global.Port); err != nil {
+					return nil, err
+				}
+				return client.NewClient(conn, services.ConnSvc), nil
+			},
+			Config: config.Global,
+			Dial:   func() (net.Conn, error) { return dial(config.Global.Host, config.Global.Port) },
+			Stdin:  os.Stdin,
+			Stdout: os.Stdout,
+			Stderr: os.Stderr,
+		}
+		code := commands.Run(context.Background(), env, flag.Args())
+		if conn != nil {
+			conn.Close()
+		}
+		os.Exit(code)
+	}
+
+	// Initialise server connection
+	conn, err := connect(config.Global.Host, config.Global.Port)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		os.Exit(commands.ExitTransportError)
+	}
+	defer conn.Close()
+	c := client.NewClient(conn, services.ConnSvc)
+
+	// Ctrl-C cancels the in-flight operation instead of exiting
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
 
 	// Handle user actions
 	actionIdx := -1
@@ -69,13 +222,36 @@ func main() {
 			return
 		}
 
-		handlers.HandleOpenAccount(action, conn)
-		handlers.HandleCloseAccount(action, conn)
-		handlers.HandleGetBalance(action, conn)
-		handlers.HandleDeposit(action, conn)
-		handlers.HandleWithdraw(action, conn)
-		handlers.HandleMonitor(action, conn)
-		handlers.HandleCheckState(action, conn)
-		handlers.HandleTransfer(action, conn)
+		handleAction(action, c, interrupts)
+	}
+}
+
+func handleAction(action models.UserSelectedAction, c *client.Client, interrupts <-chan os.Signal) {
+	// Discard interrupts received while no operation was in flight
+	select {
+	case <-interrupts:
+	default:
 	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-interrupts:
+			fmt.Println("Cancelling ...")
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	handlers.HandleOpenAccount(ctx, action, c)
+	handlers.HandleCloseAccount(ctx, action, c)
+	handlers.HandleGetBalance(ctx, action, c)
+	handlers.HandleDeposit(ctx, action, c)
+	handlers.HandleWithdraw(ctx, action, c)
+	handlers.HandleMonitor(ctx, action, c)
+	handlers.HandleCheckState(ctx, action, c)
+	handlers.HandleTransfer(ctx, action, c)
+	handlers.HandleLogin(ctx, action, c)
+	handlers.HandleLogout(ctx, action, c)
 }
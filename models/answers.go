@@ -0,0 +1,110 @@
+package models
+
+import apiModels "github.com/chiahsoon/cz4013-client/api/models"
+
+// Prompt answers for actions with a currency, which survey can only write into plain strings.
+// Amounts are only parsed into Money once the currency is known.
+
+type OpenAccountAnswers struct {
+	Name           string
+	Password       string
+	Currency       string
+	InitialBalance string
+}
+
+// SelectedCurrency is read by the amount prompt, after survey has written the currency answer
+func (a *OpenAccountAnswers) SelectedCurrency() apiModels.Currency {
+	return apiModels.Currency(a.Currency)
+}
+
+func (a OpenAccountAnswers) Request() (apiModels.OpenAccountReq, error) {
+	balance, err := apiModels.ParseMoney(a.InitialBalance, apiModels.Currency(a.Currency))
+	if err != nil {
+		return apiModels.OpenAccountReq{}, err
+	}
+
+	return apiModels.OpenAccountReq{Name: a.Name, Password: a.Password, InitialBalance: balance}, nil
+}
+
+type GetBalanceAnswers struct {
+	AccountNumber int
+	Name          string
+	Password      string
+	Currency      string
+}
+
+func (a GetBalanceAnswers) Request() (apiModels.GetBalanceReq, error) {
+	currency := apiModels.Currency(a.Currency)
+	if err := currency.Validate(); err != nil {
+		return apiModels.GetBalanceReq{}, err
+	}
+
+	return apiModels.GetBalanceReq{AccountNumber: a.AccountNumber, Name: a.Name, Password: a.Password, Currency: currency}, nil
+}
+
+type UpdateBalanceAnswers struct {
+	AccountNumber int
+	Name          string
+	Password      string
+	Currency      string
+	Amount        string
+}
+
+// SelectedCurrency is read by the amount prompt, after survey has written the currency answer
+func (a *UpdateBalanceAnswers) SelectedCurrency() apiModels.Currency {
+	return apiModels.Currency(a.Currency)
+}
+
+func (a UpdateBalanceAnswers) Request() (apiModels.UpdateBalanceReq, error) {
+	amount, err := apiModels.ParseMoney(a.Amount, apiModels.Currency(a.Currency))
+	if err != nil {
+		return apiModels.UpdateBalanceReq{}, err
+	}
+
+	return apiModels.UpdateBalanceReq{AccountNumber: a.AccountNumber, Name: a.Name, Password: a.Password, Amount: amount}, nil
+}
+
+type TransferAnswers struct {
+	AccountNumber     int
+	DestAccountNumber int
+	Name              string
+	Password          string
+	Currency          string
+	Amount            string
+}
+
+// SelectedCurrency is read by the amount prompt, after survey has written the currency answer
+func (a *TransferAnswers) SelectedCurrency() apiModels.Currency {
+	return apiModels.Currency(a.Currency)
+}
+
+func (a TransferAnswers) Request() (apiModels.TransferReq, error) {
+	amount, err := apiModels.ParseMoney(a.Amount, apiModels.Currency(a.Currency))
+	if err != nil {
+		return apiModels.TransferReq{}, err
+	}
+
+	return apiModels.TransferReq{
+		AccountNumber:     a.AccountNumber,
+		DestAccountNumber: a.DestAccountNumber,
+		Name:              a.Name,
+		Password:          a.Password,
+		Amount:            amount,
+	}, nil
+}
+
+// TransferSummary is shown for confirmation before a transfer is sent
+type TransferSummary struct {
+	From   int
+	To     int
+	Amount apiModels.Money
+	Memo   string
+}
+
+func NewTransferSummary(req apiModels.TransferReq) TransferSummary {
+	summary := TransferSummary{From: req.AccountNumber, To: req.DestAccountNumber, Amount: req.Amount}
+	if req.Memo != nil {
+		summary.Memo = *req.Memo
+	}
+	return summary
+}
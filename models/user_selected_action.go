@@ -15,6 +15,8 @@ const (
 	TransferAction
 	MonitorAction
 	CheckStateAction
+	LoginAction
+	LogoutAction
 )
 
 var AllActions = []UserSelectedAction{
@@ -26,6 +28,8 @@ var AllActions = []UserSelectedAction{
 	TransferAction,
 	MonitorAction,
 	CheckStateAction,
+	LoginAction,
+	LogoutAction,
 }
 
 func (a UserSelectedAction) IsValid() error {
@@ -57,7 +61,45 @@ func (a UserSelectedAction) Description() string {
 		return "Check Bank State (admin)"
 	case TransferAction:
 		return "Transfer Funds"
+	case LoginAction:
+		return "Log In"
+	case LogoutAction:
+		return "Log Out"
 	default:
 		return "Unknown action"
 	}
 }
+
+// Name of the non-interactive subcommand for the action, empty for actions that are only in the menu
+func (a UserSelectedAction) Command() string {
+	switch a {
+	case OpenAccountAction:
+		return "open"
+	case CloseAccountAction:
+		return "close"
+	case GetBalanceAction:
+		return "balance"
+	case DepositAction:
+		return "deposit"
+	case WithdrawAction:
+		return "withdraw"
+	case MonitorAction:
+		return "monitor"
+	case CheckStateAction:
+		return "check-state"
+	case TransferAction:
+		return "transfer"
+	default:
+		return ""
+	}
+}
+
+func ActionForCommand(command string) (UserSelectedAction, error) {
+	for _, action := range AllActions {
+		if command != "" && action.Command() == command {
+			return action, nil
+		}
+	}
+
+	return -1, errors.New("invalid command")
+}
@@ -0,0 +1,252 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	apiModels "github.com/chiahsoon/cz4013-client/api/models"
+)
+
+// Bank is an in-memory account table
+type Bank struct {
+	Rates      *ExchangeRates // Converts transfers between accounts in different currencies, nil to reject them
+	SessionTTL time.Duration  // How long a login lasts without being refreshed, DefaultSessionTTL if not set
+	mu         sync.Mutex
+	accounts   map[int]*apiModels.Account
+	sessions   map[string]*session
+	nextNumber int
+}
+
+func NewBank() *Bank {
+	return &Bank{
+		accounts:   map[int]*apiModels.Account{},
+		sessions:   map[string]*session{},
+		nextNumber: 1,
+	}
+}
+
+func (b *Bank) OpenAccount(req apiModels.OpenAccountReq) (apiModels.Account, error) {
+	if req.Name == "" || req.Password == "" {
+		return apiModels.Account{}, errors.New("name and password are required")
+	}
+	if err := req.InitialBalance.Currency.Validate(); err != nil {
+		return apiModels.Account{}, err
+	}
+	if req.InitialBalance.IsNegative() {
+		return apiModels.Account{}, errors.New("initial balance cannot be negative")
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	acc := &apiModels.Account{
+		Number:     b.nextNumber,
+		HolderName: req.Name,
+		Password:   req.Password,
+		Balance:    req.InitialBalance,
+	}
+	b.accounts[acc.Number] = acc
+	b.nextNumber++
+	return *acc, nil
+}
+
+func (b *Bank) CloseAccount(req apiModels.CloseAccountReq, token string) (apiModels.Account, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	acc, err := b.authenticate(req.AccountNumber, req.Name, req.Password, token)
+	if err != nil {
+		return apiModels.Account{}, err
+	}
+
+	delete(b.accounts, acc.Number)
+	b.endSessions(acc.Number)
+	return *acc, nil
+}
+
+func (b *Bank) GetBalance(req apiModels.GetBalanceReq, token string) (apiModels.Account, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	acc, err := b.authenticate(req.AccountNumber, req.Name, req.Password, token)
+	if err != nil {
+		return apiModels.Account{}, err
+	}
+	if err := b.checkCurrency(acc, req.Currency); err != nil {
+		return apiModels.Account{}, err
+	}
+
+	return *acc, nil
+}
+
+// Positive amounts are deposits, negative amounts are withdrawals
+func (b *Bank) UpdateBalance(req apiModels.UpdateBalanceReq, token string) (apiModels.Account, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	acc, err := b.authenticate(req.AccountNumber, req.Name, req.Password, token)
+	if err != nil {
+		return apiModels.Account{}, err
+	}
+	if err := b.checkCurrency(acc, req.Amount.Currency); err != nil {
+		return apiModels.Account{}, err
+	}
+
+	balance, err := acc.Balance.Add(req.Amount)
+	if err != nil {
+		return apiModels.Account{}, err
+	}
+	if balance.IsNegative() {
+		return apiModels.Account{}, errors.New("insufficient balance")
+	}
+
+	acc.Balance = balance
+	return *acc, nil
+}
+
+func (b *Bank) Transfer(req apiModels.TransferReq, token string) (apiModels.Account, apiModels.Account, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	src, err := b.authenticate(req.AccountNumber, req.Name, req.Password, token)
+	if err != nil {
+		return apiModels.Account{}, apiModels.Account{}, err
+	}
+	if err := b.checkCurrency(src, req.Amount.Currency); err != nil {
+		return apiModels.Account{}, apiModels.Account{}, err
+	}
+
+	dest, ok := b.accounts[req.DestAccountNumber]
+	if !ok {
+		return apiModels.Account{}, apiModels.Account{}, fmt.Errorf("destination account %d does not exist", req.DestAccountNumber)
+	}
+	if dest.Number == src.Number {
+		return apiModels.Account{}, apiModels.Account{}, errors.New("cannot transfer to the same account")
+	}
+	received, err := b.convert(req.Amount, dest)
+	if err != nil {
+		return apiModels.Account{}, apiModels.Account{}, err
+	}
+
+	srcBalance, err := src.Balance.Sub(req.Amount)
+	if err != nil {
+		return apiModels.Account{}, apiModels.Account{}, err
+	}
+	if srcBalance.IsNegative() {
+		return apiModels.Account{}, apiModels.Account{}, errors.New("insufficient balance")
+	}
+	destBalance, err := dest.Balance.Add(received)
+	if err != nil {
+		return apiModels.Account{}, apiModels.Account{}, err
+	}
+
+	src.Balance = srcBalance
+	dest.Balance = destBalance
+	return *src, *dest, nil
+}
+
+// QuoteTransfer returns what a transfer of amount would credit to the destination account, and the rate used
+func (b *Bank) QuoteTransfer(req apiModels.QuoteTransferReq, token string) (apiModels.Money, *big.Rat, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	src, err := b.authenticate(req.AccountNumber, req.Name, req.Password, token)
+	if err != nil {
+		return apiModels.Money{}, nil, err
+	}
+	if err := b.checkCurrency(src, req.Amount.Currency); err != nil {
+		return apiModels.Money{}, nil, err
+	}
+
+	dest, ok := b.accounts[req.DestAccountNumber]
+	if !ok {
+		return apiModels.Money{}, nil, fmt.Errorf("destination account %d does not exist", req.DestAccountNumber)
+	}
+	if err := req.Amount.Currency.Validate(); err != nil {
+		return apiModels.Money{}, nil, err
+	}
+
+	received, err := b.convert(req.Amount, dest)
+	if err != nil {
+		return apiModels.Money{}, nil, err
+	}
+	if req.Amount.Currency == dest.Balance.Currency {
+		return received, big.NewRat(1, 1), nil
+	}
+
+	rate, err := b.Rates.Rate(req.Amount.Currency, dest.Balance.Currency)
+	if err != nil {
+		return apiModels.Money{}, nil, err
+	}
+	return received, rate, nil
+}
+
+// Passwords are left out of the snapshot
+func (b *Bank) Accounts() []apiModels.Account {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	accounts := make([]apiModels.Account, 0, len(b.accounts))
+	for _, acc := range b.accounts {
+		snapshot := *acc
+		snapshot.Password = ""
+		accounts = append(accounts, snapshot)
+	}
+
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].Number < accounts[j].Number })
+	return accounts
+}
+
+// A session token stands in for the name and password. Its account is used when number is zero, and must
+// otherwise match number.
+func (b *Bank) authenticate(number int, name, password, token string) (*apiModels.Account, error) {
+	// Callers must hold b.mu
+	if token != "" {
+		s, err := b.session(token)
+		if err != nil {
+			return nil, err
+		}
+		if number != 0 && number != s.number {
+			return nil, fmt.Errorf("logged in to account %d, not account %d", s.number, number)
+		}
+		number = s.number
+		if acc, ok := b.accounts[number]; ok {
+			return acc, nil
+		}
+	}
+
+	acc, ok := b.accounts[number]
+	if !ok {
+		return nil, fmt.Errorf("account %d does not exist", number)
+	}
+	if acc.HolderName != name || acc.Password != password {
+		return nil, errors.New("invalid name or password")
+	}
+	return acc, nil
+}
+
+// convert returns amount in the currency of dest. Callers must hold b.mu
+func (b *Bank) convert(amount apiModels.Money, dest *apiModels.Account) (apiModels.Money, error) {
+	if !amount.IsPositive() {
+		return apiModels.Money{}, errors.New("transfer amount must be positive")
+	}
+	if amount.Currency == dest.Balance.Currency {
+		return amount, nil
+	}
+	if b.Rates == nil {
+		return apiModels.Money{}, errors.New("destination account uses a different currency")
+	}
+
+	received, err := b.Rates.Convert(amount, dest.Balance.Currency)
+	if err != nil {
+		return apiModels.Money{}, err
+	}
+	if !received.IsPositive() {
+		return apiModels.Money{}, fmt.Errorf("%s is too small to convert to %s", amount, dest.Balance.Currency)
+	}
+	return received, nil
+}
+
+func (b *Bank) checkCurrency(acc *apiModels.Account, currency apiModels.Currency) error {
+	if acc.Balance.Currency != currency {
+		return fmt.Errorf("account %d is denominated in %s", acc.Number, acc.Balance.Currency)
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/chiahsoon/cz4013-client/api"
+	"github.com/chiahsoon/cz4013-client/api/codec"
+	apiModels "github.com/chiahsoon/cz4013-client/api/models"
+)
+
+func handleOpenAccount(s *Server, req api.Request, addr net.Addr) (interface{}, error) {
+	reqData := apiModels.OpenAccountReq{}
+	if err := decodeData(req, &reqData); err != nil {
+		return nil, err
+	}
+
+	acc, err := s.Bank.OpenAccount(reqData)
+	if err != nil {
+		return nil, err
+	}
+
+	s.notify(fmt.Sprintf("Account %d opened by %s with balance %s", acc.Number, acc.HolderName, acc.Balance))
+	return apiModels.OpenAccountResp{Message: fmt.Sprintf("Account opened with account number %d", acc.Number)}, nil
+}
+
+func handleCloseAccount(s *Server, req api.Request, addr net.Addr) (interface{}, error) {
+	reqData := apiModels.CloseAccountReq{}
+	if err := decodeData(req, &reqData); err != nil {
+		return nil, err
+	}
+
+	acc, err := s.Bank.CloseAccount(reqData, req.Token)
+	if err != nil {
+		return nil, err
+	}
+
+	s.notify(fmt.Sprintf("Account %d closed", acc.Number))
+	return apiModels.CloseAccountResp{Message: fmt.Sprintf("Account %d closed", acc.Number)}, nil
+}
+
+func handleGetBalance(s *Server, req api.Request, addr net.Addr) (interface{}, error) {
+	reqData := apiModels.GetBalanceReq{}
+	if err := decodeData(req, &reqData); err != nil {
+		return nil, err
+	}
+
+	acc, err := s.Bank.GetBalance(reqData, req.Token)
+	if err != nil {
+		return nil, err
+	}
+
+	return apiModels.GetBalanceResp{Balance: acc.Balance}, nil
+}
+
+func handleUpdateBalance(s *Server, req api.Request, addr net.Addr) (interface{}, error) {
+	reqData := apiModels.UpdateBalanceReq{}
+	if err := decodeData(req, &reqData); err != nil {
+		return nil, err
+	}
+
+	acc, err := s.Bank.UpdateBalance(reqData, req.Token)
+	if err != nil {
+		return nil, err
+	}
+
+	s.notify(fmt.Sprintf("Account %d updated by %s, balance is now %s", acc.Number, reqData.Amount, acc.Balance))
+	return apiModels.UpdateBalanceResp{Balance: acc.Balance}, nil
+}
+
+func handleTransfer(s *Server, req api.Request, addr net.Addr) (interface{}, error) {
+	reqData := apiModels.TransferReq{}
+	if err := decodeData(req, &reqData); err != nil {
+		return nil, err
+	}
+
+	src, dest, err := s.Bank.Transfer(reqData, req.Token)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := fmt.Sprintf("%s transferred from account %d to account %d", reqData.Amount, src.Number, dest.Number)
+	if dest.Balance.Currency != reqData.Amount.Currency {
+		msg = fmt.Sprintf("%s transferred from account %d to account %d in %s", reqData.Amount, src.Number, dest.Number, dest.Balance.Currency)
+	}
+	if reqData.Memo != nil {
+		msg += fmt.Sprintf(" (%s)", *reqData.Memo)
+	}
+	s.notify(msg)
+	return apiModels.TransferResp{Balance: src.Balance}, nil
+}
+
+func handleQuoteTransfer(s *Server, req api.Request, addr net.Addr) (interface{}, error) {
+	reqData := apiModels.QuoteTransferReq{}
+	if err := decodeData(req, &reqData); err != nil {
+		return nil, err
+	}
+
+	received, rate, err := s.Bank.QuoteTransfer(reqData, req.Token)
+	if err != nil {
+		return nil, err
+	}
+
+	return apiModels.QuoteTransferResp{Received: received, Rate: formatRate(rate)}, nil
+}
+
+func handleLogin(s *Server, req api.Request, addr net.Addr) (interface{}, error) {
+	reqData := apiModels.LoginReq{}
+	if err := decodeData(req, &reqData); err != nil {
+		return nil, err
+	}
+
+	return s.Bank.Login(reqData)
+}
+
+func handleRefresh(s *Server, req api.Request, addr net.Addr) (interface{}, error) {
+	return s.Bank.Refresh(req.Token)
+}
+
+func handleLogout(s *Server, req api.Request, addr net.Addr) (interface{}, error) {
+	s.Bank.Logout(req.Token)
+	return "Logged out", nil
+}
+
+func handleMonitor(s *Server, req api.Request, addr net.Addr) (interface{}, error) {
+	reqData := apiModels.MonitorReq{}
+	if err := decodeData(req, &reqData); err != nil {
+		return nil, err
+	}
+	if reqData.Interval <= 0 {
+		return nil, errors.New("the monitoring interval must be larger than zero seconds")
+	}
+
+	s.monitors.add(addr, time.Duration(reqData.Interval)*time.Second)
+	return fmt.Sprintf("Monitoring updates for %d seconds", reqData.Interval), nil
+}
+
+func handleCheckState(s *Server, req api.Request, addr net.Addr) (interface{}, error) {
+	return s.Bank.Accounts(), nil
+}
+
+// Six decimal places is enough for display, e.g. "0.006727" or "148.648649"
+func formatRate(rate *big.Rat) string {
+	s := strings.TrimRight(rate.FloatString(6), "0")
+	return strings.TrimSuffix(s, ".")
+}
+
+func decodeData(req api.Request, dest interface{}) error {
+	c := codec.Codec{}
+	if err := c.DecodeAsInterface(req.Data, dest); err != nil {
+		return fmt.Errorf("invalid request data: %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"sync"
+	"time"
+)
+
+// How long the replies of a client that has sent nothing are kept when Server.HistoryTTL is not set
+const DefaultHistoryTTL = 10 * time.Minute
+
+// Reply history used to filter duplicate requests under at-most-once semantics
+type replyHistory struct {
+	mu      sync.Mutex
+	clients map[string]*clientHistory
+}
+
+type clientHistory struct {
+	ackRSN   int            // Replies below this RSN were received by the client
+	replies  map[int][]byte // RSN -> encoded reply
+	lastSeen time.Time
+}
+
+func newReplyHistory() *replyHistory {
+	return &replyHistory{clients: map[string]*clientHistory{}}
+}
+
+// Forgets clients that have sent nothing for ttl. A client that comes back after that has long given up on
+// retransmitting any request it had sent, so there are no duplicates left to filter.
+func (h *replyHistory) expire(ttl time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	now := time.Now()
+	for clientID, client := range h.clients {
+		if now.Sub(client.lastSeen) > ttl {
+			delete(h.clients, clientID)
+		}
+	}
+}
+
+// Discards replies the client has acknowledged
+func (h *replyHistory) acknowledge(clientID string, ackRSN int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	client := h.client(clientID)
+	client.lastSeen = time.Now()
+	if ackRSN <= client.ackRSN {
+		return
+	}
+
+	client.ackRSN = ackRSN
+	for rsn := range client.replies {
+		if rsn < ackRSN {
+			delete(client.replies, rsn)
+		}
+	}
+}
+
+// Requests below the acknowledged RSN are late duplicates whose replies were already received
+func (h *replyHistory) isAcknowledged(clientID string, rsn int) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return rsn < h.client(clientID).ackRSN
+}
+
+func (h *replyHistory) lookup(clientID string, rsn int) ([]byte, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	reply, ok := h.client(clientID).replies[rsn]
+	return reply, ok
+}
+
+func (h *replyHistory) store(clientID string, rsn int, reply []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.client(clientID).replies[rsn] = reply
+}
+
+func (h *replyHistory) client(clientID string) *clientHistory {
+	// Callers must hold h.mu
+	client, ok := h.clients[clientID]
+	if !ok {
+		client = &clientHistory{replies: map[int][]byte{}, lastSeen: time.Now()}
+		h.clients[clientID] = client
+	}
+	return client
+}
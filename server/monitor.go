@@ -0,0 +1,43 @@
+package server
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+type monitorRegistry struct {
+	mu       sync.Mutex
+	monitors map[string]monitor
+}
+
+type monitor struct {
+	addr  net.Addr
+	until time.Time
+}
+
+func newMonitorRegistry() *monitorRegistry {
+	return &monitorRegistry{monitors: map[string]monitor{}}
+}
+
+func (r *monitorRegistry) add(addr net.Addr, interval time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.monitors[addr.String()] = monitor{addr: addr, until: time.Now().Add(interval)}
+}
+
+// Returns addresses of clients whose interval has not ended, removing expired ones
+func (r *monitorRegistry) active() []net.Addr {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	addrs := []net.Addr{}
+	for key, m := range r.monitors {
+		if now.After(m.until) {
+			delete(r.monitors, key)
+			continue
+		}
+		addrs = append(addrs, m.addr)
+	}
+	return addrs
+}
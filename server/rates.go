@@ -0,0 +1,95 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+
+	apiModels "github.com/chiahsoon/cz4013-client/api/models"
+)
+
+// Units of each currency worth one SGD, used when nothing else is configured
+var defaultRates = map[apiModels.Currency]string{
+	"SGD": "1",
+	"USD": "0.74",
+	"EUR": "0.68",
+	"GBP": "0.58",
+	"JPY": "110",
+	"CNY": "5.35",
+	"MYR": "3.45",
+	"IDR": "11500",
+	"KRW": "1000",
+	"AUD": "1.12",
+	"BHD": "0.279",
+}
+
+// ExchangeRates converts between currencies through their value in a common base currency
+type ExchangeRates struct {
+	rates map[apiModels.Currency]*big.Rat // Units of the currency per unit of the base currency
+}
+
+func DefaultExchangeRates() *ExchangeRates {
+	rates, err := NewExchangeRates(defaultRates)
+	if err != nil {
+		panic(err)
+	}
+	return rates
+}
+
+// rates maps each currency to the decimal number of its units that one unit of the base currency is worth
+func NewExchangeRates(rates map[apiModels.Currency]string) (*ExchangeRates, error) {
+	r := &ExchangeRates{rates: map[apiModels.Currency]*big.Rat{}}
+	for currency, value := range rates {
+		if err := currency.Validate(); err != nil {
+			return nil, err
+		}
+
+		rate, ok := new(big.Rat).SetString(value)
+		if !ok || rate.Sign() <= 0 {
+			return nil, fmt.Errorf("invalid exchange rate %q for %s", value, currency)
+		}
+		r.rates[currency] = rate
+	}
+	return r, nil
+}
+
+// Rate is the number of to units that one from unit is worth
+func (r *ExchangeRates) Rate(from, to apiModels.Currency) (*big.Rat, error) {
+	if from == to {
+		return big.NewRat(1, 1), nil
+	}
+
+	fromRate, ok := r.rates[from]
+	if !ok {
+		return nil, fmt.Errorf("no exchange rate for %s", from)
+	}
+	toRate, ok := r.rates[to]
+	if !ok {
+		return nil, fmt.Errorf("no exchange rate for %s", to)
+	}
+	return new(big.Rat).Quo(toRate, fromRate), nil
+}
+
+// Convert rounds towards zero to the minor units of to, so the bank never credits more than was debited
+func (r *ExchangeRates) Convert(amount apiModels.Money, to apiModels.Currency) (apiModels.Money, error) {
+	rate, err := r.Rate(amount.Currency, to)
+	if err != nil {
+		return apiModels.Money{}, err
+	}
+
+	value := new(big.Rat).SetInt64(amount.Units)
+	value.Mul(value, rate)
+	value.Mul(value, pow10(to.MinorUnits()))
+	value.Quo(value, pow10(amount.Currency.MinorUnits()))
+
+	units := new(big.Int).Quo(value.Num(), value.Denom())
+	if !units.IsInt64() || units.Int64() == math.MinInt64 {
+		return apiModels.Money{}, errors.New("amount is too large")
+	}
+	return apiModels.NewMoney(units.Int64(), to), nil
+}
+
+func pow10(n int) *big.Rat {
+	return new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil))
+}
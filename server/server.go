@@ -0,0 +1,167 @@
+package server
+
+import (
+	"crypto/ecdh"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"time"
+
+	"github.com/chiahsoon/cz4013-client/api"
+	"github.com/chiahsoon/cz4013-client/api/codec"
+	"github.com/chiahsoon/cz4013-client/config"
+	"github.com/chiahsoon/cz4013-client/transport"
+)
+
+type handlerFunc func(s *Server, req api.Request, addr net.Addr) (interface{}, error)
+
+// Server is a reference implementation of the bank server speaking the api/codec wire format
+type Server struct {
+	Bank   *Bank
+	Logger *log.Logger
+	Key    *ecdh.PrivateKey // Requires clients to use the secure channel if set
+	// How long duplicates of a client's requests are filtered after it last sent one, DefaultHistoryTTL if 0
+	HistoryTTL time.Duration
+	conn       net.PacketConn
+	monitors   *monitorRegistry
+	history    *replyHistory
+	handlers   map[api.APIMethod]handlerFunc
+}
+
+func NewServer(bank *Bank) *Server {
+	return &Server{
+		Bank:     bank,
+		monitors: newMonitorRegistry(),
+		history:  newReplyHistory(),
+		handlers: map[api.APIMethod]handlerFunc{
+			api.OpenAccountAPI:   handleOpenAccount,
+			api.CloseAccountAPI:  handleCloseAccount,
+			api.GetBalanceAPI:    handleGetBalance,
+			api.UpdateBalanceAPI: handleUpdateBalance,
+			api.TransferAPI:      handleTransfer,
+			api.QuoteTransferAPI: handleQuoteTransfer,
+			api.MonitorAPI:       handleMonitor,
+			api.CheckStateAPI:    handleCheckState,
+			api.LoginAPI:         handleLogin,
+			api.RefreshAPI:       handleRefresh,
+			api.LogoutAPI:        handleLogout,
+		},
+	}
+}
+
+// Serve handles requests on conn until it is closed
+func (s *Server) Serve(conn net.PacketConn) error {
+	if s.Key != nil {
+		s.conn = transport.NewSecureServerConn(conn, s.Key)
+	} else {
+		s.conn = transport.NewServerConn(conn)
+	}
+	buf := make([]byte, transport.MaxMessageSize)
+	for {
+		n, addr, err := s.conn.ReadFrom(buf)
+		if err != nil {
+			var reassemblyErr *transport.ReassemblyError
+			var checksumErr *transport.ChecksumError
+			var secureErr *transport.SecureError
+			if errors.As(err, &reassemblyErr) || errors.As(err, &checksumErr) || errors.As(err, &secureErr) {
+				s.logf("dropping request: %s", err)
+				continue
+			}
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+
+		data := append([]byte{}, buf[:n]...)
+		s.handle(data, addr)
+	}
+}
+
+func (s *Server) handle(data []byte, addr net.Addr) {
+	c := codec.Codec{}
+	req := api.Request{}
+	if err := c.Decode(data, &req); err != nil {
+		s.logf("dropping malformed request from %s: %s", addr, err)
+		return
+	}
+
+	filterDuplicates := config.InvocationSemantic(req.Semantic) == config.AtMostOnce && req.ClientID != ""
+	if filterDuplicates {
+		s.history.expire(s.historyTTL())
+		s.history.acknowledge(req.ClientID, req.AckRSN)
+		if s.history.isAcknowledged(req.ClientID, req.RSN) {
+			s.logf("dropping acknowledged duplicate from %s (rsn %d)", addr, req.RSN)
+			return
+		}
+
+		if reply, ok := s.history.lookup(req.ClientID, req.RSN); ok {
+			s.logf("%s %s (rsn %d) replayed from history", addr, req.Method, req.RSN)
+			s.send(reply, addr)
+			return
+		}
+	}
+
+	resp := api.Response{RSN: req.RSN}
+	handler, ok := s.handlers[api.APIMethod(req.Method)]
+	if !ok {
+		resp.ErrMsg = fmt.Sprintf("invalid api method %q", req.Method)
+	} else if respData, err := handler(s, req, addr); err != nil {
+		resp.ErrMsg = err.Error()
+	} else {
+		resp.Data = respData
+	}
+
+	s.logf("%s %s (rsn %d) -> %q", addr, req.Method, req.RSN, resp.ErrMsg)
+	encoded, err := s.encode(resp)
+	if err != nil {
+		s.logf("failed to encode reply to %s: %s", addr, err)
+		return
+	}
+
+	if filterDuplicates {
+		s.history.store(req.ClientID, req.RSN, encoded)
+	}
+	s.send(encoded, addr)
+}
+
+func (s *Server) historyTTL() time.Duration {
+	if s.HistoryTTL <= 0 {
+		return DefaultHistoryTTL
+	}
+	return s.HistoryTTL
+}
+
+func (s *Server) reply(resp api.Response, addr net.Addr) {
+	encoded, err := s.encode(resp)
+	if err != nil {
+		s.logf("failed to encode reply to %s: %s", addr, err)
+		return
+	}
+	s.send(encoded, addr)
+}
+
+func (s *Server) encode(resp api.Response) ([]byte, error) {
+	c := codec.Codec{}
+	return c.Encode(resp)
+}
+
+func (s *Server) send(encoded []byte, addr net.Addr) {
+	if _, err := s.conn.WriteTo(encoded, addr); err != nil {
+		s.logf("failed to send reply to %s: %s", addr, err)
+	}
+}
+
+// Sends msg to every client that is still monitoring
+func (s *Server) notify(msg string) {
+	for _, addr := range s.monitors.active() {
+		s.reply(api.Response{RSN: -1, Data: msg}, addr)
+	}
+}
+
+func (s *Server) logf(format string, args ...interface{}) {
+	if s.Logger != nil {
+		s.Logger.Printf(format, args...)
+	}
+}
@@ -0,0 +1,107 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/chiahsoon/cz4013-client/api"
+	apiModels "github.com/chiahsoon/cz4013-client/api/models"
+)
+
+// How long a session lasts without being refreshed when Bank.SessionTTL is not set
+const DefaultSessionTTL = 5 * time.Minute
+
+var errInvalidSession = errors.New(api.ErrMsgInvalidSession)
+
+type session struct {
+	number  int
+	expires time.Time
+}
+
+// Login exchanges an account's name and password for a session token
+func (b *Bank) Login(req apiModels.LoginReq) (apiModels.SessionResp, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	acc, err := b.authenticate(req.AccountNumber, req.Name, req.Password, "")
+	if err != nil {
+		return apiModels.SessionResp{}, err
+	}
+
+	token, err := newToken()
+	if err != nil {
+		return apiModels.SessionResp{}, err
+	}
+	b.expireSessions()
+	b.sessions[token] = &session{number: acc.Number}
+	return b.extend(token), nil
+}
+
+// Refresh extends a session that has not expired yet. The token stays the same, so retransmitted refreshes are
+// harmless.
+func (b *Bank) Refresh(token string) (apiModels.SessionResp, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, err := b.session(token); err != nil {
+		return apiModels.SessionResp{}, err
+	}
+	return b.extend(token), nil
+}
+
+// Logging out of a session that has already expired is not an error
+func (b *Bank) Logout(token string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.sessions, token)
+}
+
+// Callers must hold b.mu
+func (b *Bank) session(token string) (*session, error) {
+	s, ok := b.sessions[token]
+	if !ok {
+		return nil, errInvalidSession
+	}
+	if time.Now().After(s.expires) {
+		delete(b.sessions, token)
+		return nil, errInvalidSession
+	}
+	return s, nil
+}
+
+// Callers must hold b.mu
+func (b *Bank) extend(token string) apiModels.SessionResp {
+	ttl := b.SessionTTL
+	if ttl <= 0 {
+		ttl = DefaultSessionTTL
+	}
+	b.sessions[token].expires = time.Now().Add(ttl)
+	return apiModels.SessionResp{Token: token, ExpiresIn: int(ttl / time.Second)}
+}
+
+// Closing an account ends its sessions. Callers must hold b.mu
+func (b *Bank) endSessions(number int) {
+	for token, s := range b.sessions {
+		if s.number == number {
+			delete(b.sessions, token)
+		}
+	}
+}
+
+// Callers must hold b.mu
+func (b *Bank) expireSessions() {
+	now := time.Now()
+	for token, s := range b.sessions {
+		if now.After(s.expires) {
+			delete(b.sessions, token)
+		}
+	}
+}
+
+func newToken() (string, error) {
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(token), nil
+}
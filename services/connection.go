@@ -1,83 +1,236 @@
 package services
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"net"
+	"os"
+	"sync/atomic"
 	"time"
 
+	"github.com/chiahsoon/cz4013-client/api"
 	"github.com/chiahsoon/cz4013-client/api/codec"
+	apiModels "github.com/chiahsoon/cz4013-client/api/models"
 	"github.com/chiahsoon/cz4013-client/config"
+	"github.com/chiahsoon/cz4013-client/transport"
 )
 
 type ConnectionService struct {
 	config.InvocationSemantic
-	TimeoutInterval time.Duration
-	MaxRetryCount   int
+	RetryPolicy
+	MaxRetryCount int           // Retransmissions after the first attempt, -1 to retry until the deadline
+	Deadline      time.Duration // Upper bound on a whole call including retries, 0 for none
+	Window        int           // Outstanding requests allowed per connection, DefaultWindow if 0
+	OnRetry       func(attempt int, err error)
+	stats         ConnectionStats
 }
 
-func (cs *ConnectionService) Fetch(conn *net.UDPConn, req interface{}, dest interface{}) error {
-	// Assumes dest is already a pointer
+type ConnectionStats struct {
+	Requests        int64
+	Retransmissions int64
+	// Replies discarded because no outstanding request had their RSN
+	StaleReplies int64
+	// Replies dropped because they failed their checksum, or could not be opened on a secure channel
+	CorruptReplies int64
+}
+
+func (cs *ConnectionService) Stats() ConnectionStats {
+	return ConnectionStats{
+		Requests:        atomic.LoadInt64(&cs.stats.Requests),
+		Retransmissions: atomic.LoadInt64(&cs.stats.Retransmissions),
+		StaleReplies:    atomic.LoadInt64(&cs.stats.StaleReplies),
+		CorruptReplies:  atomic.LoadInt64(&cs.stats.CorruptReplies),
+	}
+}
+
+// Multiplex starts reading replies from conn, which must not be read by anything else afterwards.
+// The reader stops once conn is closed.
+func (cs *ConnectionService) Multiplex(conn net.Conn) *Multiplexer {
+	return newMultiplexer(conn, cs)
+}
+
+// Fetch is safe to call concurrently, calls beyond the window of mux wait for an outstanding one to complete
+func (cs *ConnectionService) Fetch(ctx context.Context, mux *Multiplexer, req api.Request, dest *api.Response) error {
+	req.Semantic = string(cs.InvocationSemantic)
+	call, err := mux.Register(ctx, req)
+	if err != nil {
+		return err
+	}
+	defer mux.Done(call)
+
+	// Retransmissions reuse the same encoded bytes, and therefore the same RSN
 	c := codec.Codec{}
-	encoded, err := c.Encode(req)
+	encoded, err := c.Encode(call.Request)
 	if err != nil {
 		return err
 	}
 
-	// If maybe, just fetch once regardless
-	if cs.InvocationSemantic == config.Maybe {
-		return cs.fetch(conn, encoded, dest)
+	var callDeadline time.Time
+	if cs.Deadline > 0 {
+		callDeadline = time.Now().Add(cs.Deadline)
+	}
+	if ctxDeadline, ok := ctx.Deadline(); ok && (callDeadline.IsZero() || ctxDeadline.Before(callDeadline)) {
+		callDeadline = ctxDeadline
 	}
 
-	defer conn.SetDeadline(time.Time{}) // Reset to no timeout
-	if cs.MaxRetryCount == -1 {
-		// Retry infinitely
-		for {
-			conn.SetDeadline(time.Now().Add(cs.TimeoutInterval))
-			if err := cs.fetch(conn, encoded, dest); err != nil {
-				PP.PrintError(err.Error(), "", "")
-				continue
-			}
-			return nil
+	atomic.AddInt64(&cs.stats.Requests, 1)
+	timeout := time.Duration(0)
+	for attempt := 0; ; attempt++ {
+		timeout = cs.RetryPolicy.Timeout(attempt, timeout)
+		attemptDeadline := time.Now().Add(timeout)
+		if !callDeadline.IsZero() && attemptDeadline.After(callDeadline) {
+			attemptDeadline = callDeadline
 		}
-	} else {
-		for i := 0; i < cs.MaxRetryCount; i++ {
-			conn.SetDeadline(time.Now().Add(cs.TimeoutInterval))
-			if err := cs.fetch(conn, encoded, dest); err != nil {
-				continue
-			}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		err := cs.fetch(ctx, mux, call, encoded, attemptDeadline, dest)
+		if err == nil {
 			return nil
 		}
-	}
 
-	return errors.New("failed to get response")
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if errors.Is(err, ErrConnectionClosed) {
+			return err
+		}
+
+		// If maybe, just fetch once regardless
+		if cs.InvocationSemantic == config.Maybe {
+			return err
+		}
+
+		if cs.MaxRetryCount != -1 && attempt >= cs.MaxRetryCount {
+			return fmt.Errorf("failed to get response after %d attempts: %w", attempt+1, err)
+		}
+
+		if !callDeadline.IsZero() && !time.Now().Before(callDeadline) {
+			return fmt.Errorf("failed to get response before the deadline: %w", err)
+		}
+
+		// Errors such as refused connections return early, wait out the attempt before retransmitting
+		if err := sleepContext(ctx, time.Until(attemptDeadline)); err != nil {
+			return err
+		}
+		if cs.OnRetry != nil {
+			cs.OnRetry(attempt+1, err)
+		}
+		atomic.AddInt64(&cs.stats.Retransmissions, 1)
+	}
 }
 
-func (cs *ConnectionService) SendRequest(conn *net.UDPConn, reqData []byte) error {
-	_, err := conn.Write(reqData)
+// Sends one attempt of call and waits for its reply until deadline, using a timer of its own rather than a
+// deadline on the shared connection
+func (cs *ConnectionService) fetch(ctx context.Context, mux *Multiplexer, call *Call, reqData []byte, deadline time.Time, dest *api.Response) error {
+	if err := mux.Send(reqData); err != nil {
+		return err
+	}
+
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+	resp, err := mux.Wait(ctx, call, timer.C)
 	if err != nil {
 		return err
 	}
+	*dest = resp
 	return nil
 }
 
-func (cs *ConnectionService) GetResponse(conn *net.UDPConn, dest interface{}) error {
-	// Assumes dest is already a pointer
-	respData := make([]byte, 1024)
-	n, _, err := conn.ReadFromUDP(respData)
-	if err != nil {
-		return err
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
 	}
-	respData = respData[0:n]
-	c := codec.Codec{}
-	err = c.Decode(respData, dest)
-	return err
 }
 
-func (cs *ConnectionService) fetch(conn *net.UDPConn, reqData []byte, dest interface{}) error {
-	// Assumes dest is already a pointer
-	if err := cs.SendRequest(conn, reqData); err != nil {
+// Attempts made by Ping before giving up
+const pingAttempts = 3
+
+// Ping checks that a server answers on conn before it is multiplexed, waiting up to timeout for the reply to each
+// attempt. Refused connections fail straight away.
+/*
+	The probe asks for the balance of account 0, which no server opens, so that it works against any server
+	speaking the protocol without changing anything. The error it is answered with is the reply. It is sent
+	at least once, so that servers keep no reply history for it.
+*/
+func Ping(ctx context.Context, conn net.Conn, timeout time.Duration) error {
+	req := api.NewRequest()
+	req.RSN = api.NextRSN()
+	req.Semantic = string(config.AtLeastOnce)
+	req.Method = string(api.GetBalanceAPI)
+	req.Data = apiModels.GetBalanceReq{}
+	c := codec.Codec{}
+	encoded, err := c.Encode(req)
+	if err != nil {
 		return err
 	}
 
-	return cs.GetResponse(conn, dest)
+	// Cancelling ctx unblocks the pending read. The watcher has exited before the deadline is cleared.
+	stop, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(stopped)
+		select {
+		case <-ctx.Done():
+			conn.SetReadDeadline(time.Unix(1, 0))
+		case <-stop:
+		}
+	}()
+	defer func() {
+		close(stop)
+		<-stopped
+		conn.SetReadDeadline(time.Time{})
+	}()
+
+	buf := make([]byte, transport.MaxMessageSize)
+	for attempt := 0; attempt < pingAttempts; attempt++ {
+		if _, err := conn.Write(encoded); err != nil {
+			return err
+		}
+		if err := conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+			return err
+		}
+		if err = awaitPong(conn, buf, req.RSN); err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if !errors.Is(err, os.ErrDeadlineExceeded) {
+			return err
+		}
+	}
+	return fmt.Errorf("no reply to %d pings: %w", pingAttempts, err)
+}
+
+// Reads until the reply to the ping with rsn arrives, skipping anything else
+func awaitPong(conn net.Conn, buf []byte, rsn int) error {
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			var checksumErr *transport.ChecksumError
+			var secureErr *transport.SecureError
+			if errors.As(err, &checksumErr) || errors.As(err, &secureErr) {
+				continue
+			}
+			return err
+		}
+
+		resp := api.Response{}
+		c := codec.Codec{}
+		if err := c.Decode(append([]byte{}, buf[:n]...), &resp); err == nil && resp.RSN == rsn {
+			return nil
+		}
+	}
 }
@@ -0,0 +1,240 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/chiahsoon/cz4013-client/api"
+	"github.com/chiahsoon/cz4013-client/api/codec"
+	"github.com/chiahsoon/cz4013-client/transport"
+)
+
+// Outstanding requests allowed on a connection when ConnectionService.Window is not set
+const DefaultWindow = 8
+
+// Callbacks that are not read are dropped once this many are queued
+const callbackBuffer = 64
+
+// Bounds of the pause after a failed read, which doubles while reads keep failing
+const (
+	minReadBackoff = 10 * time.Millisecond
+	maxReadBackoff = 500 * time.Millisecond
+)
+
+var ErrConnectionClosed = errors.New("connection closed")
+
+// Multiplexer lets concurrent calls share one connection.
+/*
+	- A single goroutine reads every datagram and hands replies to the call registered for their RSN
+	- Replies with a negative RSN are callbacks (e.g. monitoring updates) and go to Callbacks()
+	- Replies no call is waiting for are late duplicates and counted as stale
+	- Replies that fail their checksum or cannot be opened are counted and otherwise treated as lost
+	- Read errors such as refused connections, or a TCP connection ending, fail the current attempt of every
+	  outstanding call. Reads pause after such errors, as some persist until the connection is closed.
+*/
+type Multiplexer struct {
+	conn      net.Conn
+	id        string // ClientID of every request registered, as the RSNs and AckRSN are tracked per Multiplexer
+	svc       *ConnectionService
+	window    chan struct{} // Holds a token per outstanding call
+	callbacks chan api.Response
+	done      chan struct{} // Closed once the connection is closed
+	readErr   error         // Why the reader stopped, set before done is closed
+
+	mu        sync.Mutex
+	pending   map[int]chan reply
+	completed int // One more than the highest RSN completed
+}
+
+type reply struct {
+	resp api.Response
+	err  error
+}
+
+// Call is a registered request waiting for its reply
+type Call struct {
+	Request api.Request
+	replies chan reply
+}
+
+func newMultiplexer(conn net.Conn, svc *ConnectionService) *Multiplexer {
+	window := svc.Window
+	if window <= 0 {
+		window = DefaultWindow
+	}
+
+	m := &Multiplexer{
+		conn:      conn,
+		id:        api.NewClientID(),
+		svc:       svc,
+		window:    make(chan struct{}, window),
+		callbacks: make(chan api.Response, callbackBuffer),
+		done:      make(chan struct{}),
+		pending:   map[int]chan reply{},
+	}
+	go m.read()
+	return m
+}
+
+// Callbacks streams replies that are not for any request, such as monitoring updates
+func (m *Multiplexer) Callbacks() <-chan api.Response {
+	return m.callbacks
+}
+
+// Register waits for room in the window, then assigns the request its ClientID, RSN and AckRSN.
+/*
+	The RSN is assigned here rather than when the request is built, so that a request with a lower RSN
+	is always registered before the cumulative ack of any other request is computed. Done must be called
+	once the call completes.
+*/
+func (m *Multiplexer) Register(ctx context.Context, req api.Request) (*Call, error) {
+	select {
+	case m.window <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-m.done:
+		return nil, m.readErr
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	req.ClientID = m.id
+	req.RSN = api.NextRSN()
+	call := &Call{Request: req, replies: make(chan reply, 1)}
+	m.pending[req.RSN] = call.replies
+	call.Request.AckRSN = m.ackRSN()
+	return call, nil
+}
+
+func (m *Multiplexer) Done(call *Call) {
+	m.mu.Lock()
+	delete(m.pending, call.Request.RSN)
+	if call.Request.RSN >= m.completed {
+		m.completed = call.Request.RSN + 1
+	}
+	m.mu.Unlock()
+	<-m.window
+}
+
+// Replies to every RSN below the lowest outstanding one have been received. Callers must hold m.mu
+func (m *Multiplexer) ackRSN() int {
+	ack := m.completed
+	first := true
+	for rsn := range m.pending {
+		if first || rsn < ack {
+			ack = rsn
+			first = false
+		}
+	}
+	return ack
+}
+
+func (m *Multiplexer) Send(data []byte) error {
+	_, err := m.conn.Write(data)
+	return err
+}
+
+// Wait blocks until a reply or read error for call arrives, the attempt times out, ctx is done or the
+// connection is closed
+func (m *Multiplexer) Wait(ctx context.Context, call *Call, timeout <-chan time.Time) (api.Response, error) {
+	select {
+	case r := <-call.replies:
+		return r.resp, r.err
+	case <-timeout:
+		return api.Response{}, os.ErrDeadlineExceeded
+	case <-ctx.Done():
+		return api.Response{}, ctx.Err()
+	case <-m.done:
+		return api.Response{}, m.readErr
+	}
+}
+
+func (m *Multiplexer) read() {
+	buf := make([]byte, transport.MaxMessageSize)
+	var backoff time.Duration
+	for {
+		n, err := m.conn.Read(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				m.readErr = ErrConnectionClosed
+				close(m.done)
+				return
+			}
+			// The call a corrupted reply was for times out and retransmits, as if it was lost. Replays are
+			// duplicates of replies already dispatched.
+			var checksumErr *transport.ChecksumError
+			var secureErr *transport.SecureError
+			if errors.As(err, &secureErr) && secureErr.Replay {
+				atomic.AddInt64(&m.svc.stats.StaleReplies, 1)
+				continue
+			}
+			if errors.As(err, &checksumErr) || errors.As(err, &secureErr) {
+				atomic.AddInt64(&m.svc.stats.CorruptReplies, 1)
+				continue
+			}
+			m.broadcast(err)
+			backoff = nextReadBackoff(backoff)
+			time.Sleep(backoff)
+			continue
+		}
+		backoff = 0
+
+		// Decoded data may refer to the datagram, so it cannot share buf. Undecodable datagrams cannot be
+		// matched to a call and are dropped.
+		resp := api.Response{}
+		c := codec.Codec{}
+		if err := c.Decode(append([]byte{}, buf[:n]...), &resp); err != nil {
+			continue
+		}
+		m.dispatch(resp)
+	}
+}
+
+func (m *Multiplexer) dispatch(resp api.Response) {
+	if resp.RSN < 0 {
+		select {
+		case m.callbacks <- resp:
+		default:
+		}
+		return
+	}
+
+	m.mu.Lock()
+	replies, ok := m.pending[resp.RSN]
+	m.mu.Unlock()
+	if ok {
+		select {
+		case replies <- reply{resp: resp}:
+			return
+		default:
+		}
+	}
+	atomic.AddInt64(&m.svc.stats.StaleReplies, 1)
+}
+
+func nextReadBackoff(prev time.Duration) time.Duration {
+	if prev < minReadBackoff {
+		return minReadBackoff
+	}
+	if prev*2 > maxReadBackoff {
+		return maxReadBackoff
+	}
+	return prev * 2
+}
+
+// Transient read errors are delivered to calls without a queued reply, which then retransmit
+func (m *Multiplexer) broadcast(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, replies := range m.pending {
+		select {
+		case replies <- reply{err: err}:
+		default:
+		}
+	}
+}
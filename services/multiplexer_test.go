@@ -0,0 +1,205 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/chiahsoon/cz4013-client/api"
+	"github.com/chiahsoon/cz4013-client/api/codec"
+)
+
+// Feeds the multiplexer the datagrams sent on replies, or fails every read with err if set
+type fakeConn struct {
+	net.Conn
+	replies chan []byte
+	err     error
+	reads   int64
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+func newFakeConn() *fakeConn {
+	return &fakeConn{replies: make(chan []byte), closed: make(chan struct{})}
+}
+
+func (c *fakeConn) Read(b []byte) (int, error) {
+	atomic.AddInt64(&c.reads, 1)
+	select {
+	case <-c.closed:
+		return 0, net.ErrClosed
+	default:
+	}
+	if c.err != nil {
+		return 0, c.err
+	}
+
+	select {
+	case reply := <-c.replies:
+		return copy(b, reply), nil
+	case <-c.closed:
+		return 0, net.ErrClosed
+	}
+}
+
+func (c *fakeConn) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (c *fakeConn) Close() error {
+	c.closeOnce.Do(func() { close(c.closed) })
+	return nil
+}
+
+func (c *fakeConn) reply(t *testing.T, resp api.Response) {
+	t.Helper()
+	data, err := (&codec.Codec{}).Encode(resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.replies <- data
+}
+
+func register(t *testing.T, m *Multiplexer) *Call {
+	t.Helper()
+	call, err := m.Register(context.Background(), api.Request{Method: string(api.GetBalanceAPI)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return call
+}
+
+func wait(t *testing.T, m *Multiplexer, call *Call) api.Response {
+	t.Helper()
+	resp, err := m.Wait(context.Background(), call, time.After(5*time.Second))
+	if err != nil {
+		t.Fatalf("waiting for RSN %d: %s", call.Request.RSN, err)
+	}
+	return resp
+}
+
+func TestMultiplexerDispatchesByRSN(t *testing.T) {
+	conn := newFakeConn()
+	defer conn.Close()
+	m := (&ConnectionService{}).Multiplex(conn)
+
+	first, second := register(t, m), register(t, m)
+	if first.Request.RSN == second.Request.RSN {
+		t.Fatalf("both calls registered with RSN %d", first.Request.RSN)
+	}
+	if first.Request.ClientID == "" || first.Request.ClientID != second.Request.ClientID {
+		t.Fatalf("calls registered with ClientIDs %q and %q", first.Request.ClientID, second.Request.ClientID)
+	}
+
+	// Replies arrive in the opposite order to the requests
+	conn.reply(t, api.Response{RSN: second.Request.RSN, ErrMsg: "second"})
+	conn.reply(t, api.Response{RSN: first.Request.RSN, ErrMsg: "first"})
+	if resp := wait(t, m, first); resp.ErrMsg != "first" {
+		t.Fatalf("first call got the reply %q", resp.ErrMsg)
+	}
+	if resp := wait(t, m, second); resp.ErrMsg != "second" {
+		t.Fatalf("second call got the reply %q", resp.ErrMsg)
+	}
+
+	// Negative RSNs are callbacks rather than replies
+	conn.reply(t, api.Response{RSN: -1, ErrMsg: "update"})
+	select {
+	case resp := <-m.Callbacks():
+		if resp.ErrMsg != "update" {
+			t.Fatalf("callback %q, want update", resp.ErrMsg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("callback not delivered")
+	}
+}
+
+func TestMultiplexerDropsStaleReplies(t *testing.T) {
+	conn := newFakeConn()
+	defer conn.Close()
+	svc := &ConnectionService{}
+	m := svc.Multiplex(conn)
+
+	done := register(t, m)
+	conn.reply(t, api.Response{RSN: done.Request.RSN, ErrMsg: "reply"})
+	wait(t, m, done)
+	m.Done(done)
+
+	call := register(t, m)
+	if call.Request.AckRSN != call.Request.RSN {
+		t.Fatalf("AckRSN is %d, want %d as every earlier call completed", call.Request.AckRSN, call.Request.RSN)
+	}
+	// A late duplicate for a completed call, a reply for an RSN never sent and a duplicate of a reply not yet
+	// collected are all dropped
+	conn.reply(t, api.Response{RSN: done.Request.RSN, ErrMsg: "late duplicate"})
+	conn.reply(t, api.Response{RSN: call.Request.RSN + 1000, ErrMsg: "unknown"})
+	conn.reply(t, api.Response{RSN: call.Request.RSN, ErrMsg: "reply"})
+	conn.reply(t, api.Response{RSN: call.Request.RSN, ErrMsg: "duplicate"})
+	// Replies are dispatched in order, so the ones above have been once this is read
+	conn.reply(t, api.Response{RSN: -1})
+	<-m.Callbacks()
+
+	if resp := wait(t, m, call); resp.ErrMsg != "reply" {
+		t.Fatalf("call got the reply %q", resp.ErrMsg)
+	}
+	if stale := svc.Stats().StaleReplies; stale != 3 {
+		t.Fatalf("%d replies counted as stale, want 3", stale)
+	}
+}
+
+func TestMultiplexerClose(t *testing.T) {
+	conn := newFakeConn()
+	m := (&ConnectionService{Window: 1}).Multiplex(conn)
+	call := register(t, m)
+
+	// One call waiting for its reply and another for room in the window
+	registered := make(chan error, 1)
+	go func() {
+		_, err := m.Register(context.Background(), api.Request{})
+		registered <- err
+	}()
+	waited := make(chan error, 1)
+	go func() {
+		_, err := m.Wait(context.Background(), call, nil)
+		waited <- err
+	}()
+
+	conn.Close()
+	for _, ch := range []chan error{waited, registered} {
+		select {
+		case err := <-ch:
+			if !errors.Is(err, ErrConnectionClosed) {
+				t.Fatalf("got error %v, want ErrConnectionClosed", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("call still waiting after the connection was closed")
+		}
+	}
+
+	if _, err := m.Register(context.Background(), api.Request{}); !errors.Is(err, ErrConnectionClosed) {
+		t.Fatalf("Register after Close returned %v, want ErrConnectionClosed", err)
+	}
+}
+
+func TestMultiplexerBacksOffFailedReads(t *testing.T) {
+	conn := newFakeConn()
+	conn.err = syscall.ECONNREFUSED
+	m := (&ConnectionService{}).Multiplex(conn)
+	call := register(t, m)
+
+	// The error fails the outstanding attempt
+	if _, err := m.Wait(context.Background(), call, time.After(5*time.Second)); !errors.Is(err, syscall.ECONNREFUSED) {
+		t.Fatalf("got error %v, want the read error", err)
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	conn.Close()
+	if reads := atomic.LoadInt64(&conn.reads); reads > 10 {
+		t.Fatalf("%d reads in 200ms while every read fails", reads)
+	}
+}
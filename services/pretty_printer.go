@@ -2,21 +2,30 @@ package services
 
 import (
 	"fmt"
+	"io"
+	"os"
 	"reflect"
 	"strings"
 )
 
 const printBoundary = "================================="
 
-type PrettyPrinter struct{}
+type PrettyPrinter struct {
+	Out io.Writer // Defaults to stdout
+}
 
 func (pp *PrettyPrinter) Print(data interface{}, header, footer string) {
 	pp.printBoundary(pp.printByKind, header, footer, data)
 }
 
+// Prints data without boundaries, for output consumed by scripts
+func (pp *PrettyPrinter) PrintPlain(data interface{}) {
+	pp.printByKind(data)
+}
+
 func (pp *PrettyPrinter) PrintMessage(msg, header, footer string) {
 	fn := func(interface{}) {
-		fmt.Println(strings.Title(msg))
+		pp.println(strings.Title(msg))
 	}
 	pp.printBoundary(fn, header, footer, msg)
 }
@@ -25,6 +34,14 @@ func (pp *PrettyPrinter) PrintError(errMsg, header, footer string) {
 	pp.PrintMessage("Error: "+errMsg, header, footer)
 }
 
+func (pp *PrettyPrinter) println(a ...interface{}) {
+	out := pp.Out
+	if out == nil {
+		out = os.Stdout
+	}
+	fmt.Fprintln(out, a...)
+}
+
 func (pp *PrettyPrinter) printByKind(data interface{}) {
 	rv := reflect.ValueOf(data)
 	switch rv.Kind() {
@@ -40,7 +57,7 @@ func (pp *PrettyPrinter) printByKind(data interface{}) {
 	case reflect.Slice:
 		pp.printSlice(rv.Interface())
 	default:
-		fmt.Println(data)
+		pp.println(data)
 	}
 }
 
@@ -52,7 +69,7 @@ func (pp *PrettyPrinter) printStruct(data interface{}) {
 	for i := 0; i < s.NumField(); i++ {
 		field := s.Field(i)
 		line := fmt.Sprintf("%s:  %v", dataType.Field(i).Name, field.Interface())
-		fmt.Println(strings.TrimSuffix(line, "\n"))
+		pp.println(strings.TrimSuffix(line, "\n"))
 	}
 }
 
@@ -61,11 +78,11 @@ func (pp *PrettyPrinter) printMap(data interface{}) {
 	if dataMap, ok := data.(map[string]interface{}); ok {
 		for key, value := range dataMap {
 			line := fmt.Sprintf("%s:  %v", key, value)
-			fmt.Println(strings.TrimSuffix(line, "\n"))
+			pp.println(strings.TrimSuffix(line, "\n"))
 		}
 		return
 	}
-	fmt.Println(data)
+	pp.println(data)
 }
 
 func (pp *PrettyPrinter) printSlice(data interface{}) {
@@ -74,7 +91,7 @@ func (pp *PrettyPrinter) printSlice(data interface{}) {
 		sliceItem := rv.Index(i).Interface()
 		sliceItemRv := reflect.ValueOf(sliceItem)
 		if i > 0 {
-			fmt.Println()
+			pp.println()
 		}
 
 		pp.printByKind(sliceItemRv.Interface())
@@ -82,13 +99,13 @@ func (pp *PrettyPrinter) printSlice(data interface{}) {
 }
 
 func (pp *PrettyPrinter) printBoundary(printDataFn func(interface{}), header, footer string, data interface{}) {
-	fmt.Println(printBoundary)
+	pp.println(printBoundary)
 	if len(header) > 0 {
-		fmt.Println(header)
+		pp.println(header)
 	}
 	printDataFn(data)
 	if len(footer) > 0 {
-		fmt.Println(footer)
+		pp.println(footer)
 	}
-	fmt.Println(printBoundary)
+	pp.println(printBoundary)
 }
@@ -1,14 +1,17 @@
 package services
 
 import (
-	"errors"
-	"strconv"
+	"fmt"
 
 	"github.com/AlecAivazis/survey/v2"
+	"github.com/AlecAivazis/survey/v2/core"
+	apiModels "github.com/chiahsoon/cz4013-client/api/models"
 	"github.com/chiahsoon/cz4013-client/models"
 )
 
-type UIService struct{}
+type UIService struct {
+	DefaultCurrency apiModels.Currency // Preselected in the currency prompt if set
+}
 
 func (ui *UIService) GetMainPrompt() *survey.Select {
 	options := []string{}
@@ -22,25 +25,70 @@ func (ui *UIService) GetMainPrompt() *survey.Select {
 	}
 }
 
-func (ui *UIService) GetSubPromptsForAction() map[models.UserSelectedAction][]*survey.Question {
+// currency returns the currency answered so far, amounts are validated against its precision. May be nil if the
+// action has no amount.
+func (ui *UIService) GetSubPromptsForAction(currency func() apiModels.Currency) map[models.UserSelectedAction][]*survey.Question {
 	// Return new instances of the prompts
 	return map[models.UserSelectedAction][]*survey.Question{
-		models.OpenAccountAction:  {ui.getNameQn(), ui.getCurrencyQn(), ui.getInitialAccountBalanceQn(), ui.getPasswordQn()},
+		models.OpenAccountAction:  {ui.getNameQn(), ui.getCurrencyQn(), ui.getInitialAccountBalanceQn(currency), ui.getNewPasswordQn()},
 		models.CloseAccountAction: {ui.getAccountNumberQn(), ui.getNameQn(), ui.getPasswordQn()},
 		models.GetBalanceAction:   {ui.getAccountNumberQn(), ui.getNameQn(), ui.getCurrencyQn(), ui.getPasswordQn()},
-		models.DepositAction:      {ui.getAccountNumberQn(), ui.getNameQn(), ui.getCurrencyQn(), ui.getAmountQn(), ui.getPasswordQn()},
-		models.WithdrawAction:     {ui.getAccountNumberQn(), ui.getNameQn(), ui.getCurrencyQn(), ui.getAmountQn(), ui.getPasswordQn()},
+		models.DepositAction:      {ui.getAccountNumberQn(), ui.getNameQn(), ui.getCurrencyQn(), ui.getAmountQn(currency), ui.getPasswordQn()},
+		models.WithdrawAction:     {ui.getAccountNumberQn(), ui.getNameQn(), ui.getCurrencyQn(), ui.getAmountQn(currency), ui.getPasswordQn()},
 		models.MonitorAction:      {ui.getIntervalQn()},
-		models.TransferAction:     {ui.getAccountNumberQn(), ui.getDestAccountNumberQn(), ui.getNameQn(), ui.getCurrencyQn(), ui.getAmountQn(), ui.getPasswordQn()},
+		models.TransferAction:     {ui.getAccountNumberQn(), ui.getDestAccountNumberQn(), ui.getNameQn(), ui.getCurrencyQn(), ui.getAmountQn(currency), ui.getPasswordQn()},
+		models.LoginAction:        {ui.getAccountNumberQn(), ui.getNameQn(), ui.getPasswordQn()},
+	}
+}
+
+// Leaves out the account number, name and password, which a session stands in for
+func (ui *UIService) WithoutCredentials(qns []*survey.Question) []*survey.Question {
+	filtered := []*survey.Question{}
+	for _, qn := range qns {
+		switch qn.Name {
+		case "accountNumber", "name", "password":
+			continue
+		}
+		filtered = append(filtered, qn)
+	}
+	return filtered
+}
+
+// Asked separately from the transfer prompts as it may be left empty
+func (ui *UIService) GetMemoPrompt() *survey.Input {
+	return &survey.Input{Message: "Add a memo for the transfer? (optional)"}
+}
+
+// Choices of the transfer confirmation prompt
+const (
+	TransferSend    = "Send"
+	TransferPreview = "Preview the amount received (asks the server)"
+	TransferCancel  = "Cancel"
+)
+
+// Cancelling by default, as the transfer cannot be undone. The preview is offered until it has been shown.
+func (ui *UIService) GetTransferConfirmPrompt(previewed bool) *survey.Select {
+	options := []string{TransferSend, TransferPreview, TransferCancel}
+	if previewed {
+		options = []string{TransferSend, TransferCancel}
 	}
+	return &survey.Select{Message: "Send this transfer?", Options: options, Default: TransferCancel}
 }
 
 func (ui *UIService) getPasswordQn() *survey.Question {
 	return ui.makePasswordQuestion("password", "What is your password?")
 }
 
-func (ui *UIService) getInitialAccountBalanceQn() *survey.Question {
-	return ui.makeTextQuestion("initialBalance", "What is your initial account balance?")
+func (ui *UIService) getNewPasswordQn() *survey.Question {
+	qn := ui.makePasswordQuestion("password", fmt.Sprintf("Choose a password (at least %d letters and digits):", MinPasswordLength))
+	qn.Validate = survey.ComposeValidators(survey.Required, validateNewPassword)
+	return qn
+}
+
+func (ui *UIService) getInitialAccountBalanceQn(currency func() apiModels.Currency) *survey.Question {
+	qn := ui.makeTextQuestion("initialBalance", "What is your initial account balance?")
+	qn.Validate = survey.ComposeValidators(survey.Required, validateAmount(currency, true))
+	return qn
 }
 
 func (ui *UIService) getNameQn() *survey.Question {
@@ -48,26 +96,64 @@ func (ui *UIService) getNameQn() *survey.Question {
 }
 
 func (ui *UIService) getAccountNumberQn() *survey.Question {
-	return ui.makeTextQuestion("accountNumber", "What is your account number?")
+	qn := ui.makeTextQuestion("accountNumber", "What is your account number?")
+	qn.Validate = survey.ComposeValidators(survey.Required, validatePositiveInt("account number"))
+	return qn
 }
 
 func (ui *UIService) getDestAccountNumberQn() *survey.Question {
-	return ui.makeTextQuestion("destAccountNumber", "What is the destination account number?")
+	qn := ui.makeTextQuestion("destAccountNumber", "What is the destination account number?")
+	qn.Validate = survey.ComposeValidators(survey.Required, validatePositiveInt("account number"))
+	return qn
 }
 
+// Type to filter the currency codes
 func (ui *UIService) getCurrencyQn() *survey.Question {
-	return ui.makeTextQuestion("currency", "What is the currency?")
+	options := []string{}
+	for _, c := range apiModels.Currencies() {
+		options = append(options, string(c))
+	}
+
+	prompt := &survey.Select{
+		Message:  "What is the currency?",
+		Options:  options,
+		PageSize: 10,
+	}
+	if ui.DefaultCurrency.Validate() == nil {
+		prompt.Default = string(ui.DefaultCurrency)
+	}
+
+	return &survey.Question{
+		Name:     "currency",
+		Prompt:   prompt,
+		Validate: survey.ComposeValidators(survey.Required, ui.validateCurrency),
+	}
 }
 
-func (ui *UIService) getAmountQn() *survey.Question {
-	return ui.makeTextQuestion("amount", "What is the amount?")
+func (ui *UIService) validateCurrency(ans interface{}) error {
+	code := ""
+	switch v := ans.(type) {
+	case core.OptionAnswer:
+		code = v.Value
+	case string:
+		code = v
+	}
+
+	return apiModels.Currency(code).Validate()
+}
+
+func (ui *UIService) getAmountQn(currency func() apiModels.Currency) *survey.Question {
+	qn := ui.makeTextQuestion("amount", "What is the amount?")
+	qn.Validate = survey.ComposeValidators(survey.Required, validateAmount(currency, false))
+	return qn
 }
 
 func (ui *UIService) makeTextQuestion(name string, message string) *survey.Question {
 	return &survey.Question{
-		Name:     name,
-		Prompt:   &survey.Input{Message: message},
-		Validate: survey.Required,
+		Name:      name,
+		Prompt:    &survey.Input{Message: message},
+		Validate:  survey.Required,
+		Transform: trimSpace,
 	}
 }
 
@@ -81,24 +167,9 @@ func (ui *UIService) makePasswordQuestion(name string, message string) *survey.Q
 
 func (ui *UIService) getIntervalQn() *survey.Question {
 	return &survey.Question{
-		Name:   "interval",
-		Prompt: &survey.Input{Message: "What is the monitoring interval (seconds)?"},
-		Validate: func(val interface{}) error {
-			strVal, ok := val.(string)
-			if !ok {
-				return errors.New("invalid interval")
-			}
-
-			intVal, err := strconv.Atoi(strVal)
-			if err != nil {
-				return errors.New("invalid interval")
-			}
-
-			if intVal <= 0 {
-				return errors.New("the monitoring interval must be larger than zero seconds")
-			}
-
-			return nil
-		},
+		Name:      "interval",
+		Prompt:    &survey.Input{Message: "What is the monitoring interval (seconds)?"},
+		Validate:  survey.ComposeValidators(survey.Required, validatePositiveInt("monitoring interval")),
+		Transform: trimSpace,
 	}
 }
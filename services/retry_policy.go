@@ -0,0 +1,74 @@
+package services
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/chiahsoon/cz4013-client/config"
+)
+
+// Decides how long to wait for a reply before retransmitting
+type RetryPolicy interface {
+	// attempt starts from 0, prev is the timeout used by the previous attempt (0 for the first)
+	Timeout(attempt int, prev time.Duration) time.Duration
+}
+
+func NewRetryPolicy(cfg config.RetryConfig) RetryPolicy {
+	switch cfg.Backoff {
+	case config.ExponentialBackoff:
+		return &ExponentialBackoff{Initial: cfg.Timeout, Max: cfg.MaxTimeout, Multiplier: 2}
+	case config.JitterBackoff:
+		return &DecorrelatedJitterBackoff{Base: cfg.Timeout, Max: cfg.MaxTimeout}
+	default:
+		return &FixedBackoff{Interval: cfg.Timeout}
+	}
+}
+
+type FixedBackoff struct {
+	Interval time.Duration
+}
+
+func (b *FixedBackoff) Timeout(attempt int, prev time.Duration) time.Duration {
+	return b.Interval
+}
+
+type ExponentialBackoff struct {
+	Initial    time.Duration
+	Max        time.Duration
+	Multiplier float64
+}
+
+func (b *ExponentialBackoff) Timeout(attempt int, prev time.Duration) time.Duration {
+	if attempt == 0 || prev <= 0 {
+		return b.Initial
+	}
+
+	next := time.Duration(float64(prev) * b.Multiplier)
+	if next > b.Max || next <= 0 {
+		return b.Max
+	}
+	return next
+}
+
+// Ref: https://aws.amazon.com/blogs/architecture/exponential-backoff-and-jitter/
+type DecorrelatedJitterBackoff struct {
+	Base time.Duration
+	Max  time.Duration
+}
+
+func (b *DecorrelatedJitterBackoff) Timeout(attempt int, prev time.Duration) time.Duration {
+	if attempt == 0 {
+		return b.Base
+	}
+	if prev < b.Base {
+		prev = b.Base
+	}
+
+	// Random value in [Base, prev * 3]
+	upper := prev * 3
+	next := b.Base + time.Duration(rand.Int63n(int64(upper-b.Base)+1))
+	if next > b.Max {
+		return b.Max
+	}
+	return next
+}
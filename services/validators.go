@@ -0,0 +1,95 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/AlecAivazis/survey/v2"
+	apiModels "github.com/chiahsoon/cz4013-client/api/models"
+)
+
+/*
+	Validators for the prompts
+	- survey re-asks only the question whose validator fails, keeping earlier answers
+	- Answers are validated before trimSpace is applied, so validators trim them too
+*/
+
+const MinPasswordLength = 8
+
+var trimSpace = survey.TransformString(strings.TrimSpace)
+
+// ValidatePassword checks the policy for new accounts
+func ValidatePassword(password string) error {
+	if len(password) < MinPasswordLength {
+		return fmt.Errorf("the password must have at least %d characters", MinPasswordLength)
+	}
+	if strings.TrimSpace(password) != password {
+		return errors.New("the password cannot start or end with spaces")
+	}
+
+	hasLetter, hasDigit := false, false
+	for _, r := range password {
+		hasLetter = hasLetter || unicode.IsLetter(r)
+		hasDigit = hasDigit || unicode.IsDigit(r)
+	}
+	if !hasLetter || !hasDigit {
+		return errors.New("the password must contain both letters and digits")
+	}
+	return nil
+}
+
+func validateNewPassword(ans interface{}) error {
+	password, ok := ans.(string)
+	if !ok {
+		return errors.New("invalid password")
+	}
+	return ValidatePassword(password)
+}
+
+func validatePositiveInt(what string) survey.Validator {
+	return func(ans interface{}) error {
+		str, ok := ans.(string)
+		if !ok {
+			return fmt.Errorf("invalid %s", what)
+		}
+
+		num, err := strconv.Atoi(strings.TrimSpace(str))
+		if err != nil {
+			return fmt.Errorf("the %s must be a whole number", what)
+		}
+		if num <= 0 {
+			return fmt.Errorf("the %s must be larger than zero", what)
+		}
+		return nil
+	}
+}
+
+// currency returns the currency chosen in an earlier question, which survey has already written to the answers
+func validateAmount(currency func() apiModels.Currency, allowZero bool) survey.Validator {
+	return func(ans interface{}) error {
+		str, ok := ans.(string)
+		if !ok {
+			return errors.New("invalid amount")
+		}
+
+		var c apiModels.Currency
+		if currency != nil {
+			c = currency()
+		}
+		amount, err := apiModels.ParseMoney(str, c)
+		if err != nil {
+			return err
+		}
+
+		if amount.IsNegative() || (!allowZero && !amount.IsPositive()) {
+			if allowZero {
+				return errors.New("the amount cannot be negative")
+			}
+			return errors.New("the amount must be larger than zero")
+		}
+		return nil
+	}
+}
@@ -0,0 +1,81 @@
+package transport
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"net"
+	"sync"
+)
+
+const (
+	checksumMagic byte = 0xC5
+	// Bytes added to every datagram, on top of MaxDatagramSize
+	ChecksumHeader = 5
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// ChecksumError is returned for a datagram that failed verification, which callers should treat as lost
+type ChecksumError struct {
+	From net.Addr
+	Size int
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("corrupted datagram of %d bytes from %s", e.Size, e.From)
+}
+
+// ChecksumConn frames every datagram with a CRC32C of its payload.
+/*
+	Frame:	[magic (8-bit)][crc32c of payload (32-bit)][payload]
+
+	- It sits below fragmentation, so a corrupted fragment is recovered by a NACK rather than losing the message
+	- Datagrams that are too short, lack the magic or fail the checksum are returned as a ChecksumError
+	and never reach the codec
+*/
+type ChecksumConn struct {
+	net.PacketConn
+
+	readMu  sync.Mutex
+	readBuf []byte
+}
+
+func NewChecksumConn(pc net.PacketConn) *ChecksumConn {
+	return &ChecksumConn{PacketConn: pc}
+}
+
+func (cc *ChecksumConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	frame := make([]byte, ChecksumHeader+len(b))
+	frame[0] = checksumMagic
+	binary.BigEndian.PutUint32(frame[1:ChecksumHeader], crc32.Checksum(b, castagnoli))
+	copy(frame[ChecksumHeader:], b)
+
+	if _, err := cc.PacketConn.WriteTo(frame, addr); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+func (cc *ChecksumConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	cc.readMu.Lock()
+	defer cc.readMu.Unlock()
+	if len(cc.readBuf) < len(b)+ChecksumHeader {
+		cc.readBuf = make([]byte, len(b)+ChecksumHeader)
+	}
+
+	n, addr, err := cc.PacketConn.ReadFrom(cc.readBuf[:len(b)+ChecksumHeader])
+	if err != nil {
+		return 0, addr, err
+	}
+
+	frame := cc.readBuf[:n]
+	if n < ChecksumHeader || frame[0] != checksumMagic {
+		return 0, addr, &ChecksumError{From: addr, Size: n}
+	}
+	payload := frame[ChecksumHeader:]
+	if crc32.Checksum(payload, castagnoli) != binary.BigEndian.Uint32(frame[1:ChecksumHeader]) {
+		return 0, addr, &ChecksumError{From: addr, Size: n}
+	}
+	return copy(b, payload), addr, nil
+}
@@ -0,0 +1,186 @@
+package transport
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/chiahsoon/cz4013-client/config"
+)
+
+// Head start each address gets before the next is tried, as recommended by RFC 8305
+const DefaultStagger = 250 * time.Millisecond
+
+// Dialer connects to the first address of a host that the server answers on, in the style of happy eyeballs.
+/*
+	- Addresses alternate between families, starting with the family the resolver lists first
+	- Each address is tried Stagger after the previous one, or as soon as the previous one fails
+	- The first address to pass Probe wins, and the connections to the others are closed. If none does, the
+	  first address is used anyway, so that starting the client does not depend on the server being up
+	- Without a Probe the first address that can be dialled wins, as UDP cannot tell otherwise whether anything
+	  is listening. A host with a single address is not probed either, as there is nothing to choose between.
+	- Over TCP the standard library does the racing each time TCPTransport connects, and setting up the connection
+	  is the probe
+*/
+type Dialer struct {
+	Transport config.Transport // UDP or TCP, unix sockets have no addresses to choose from
+	Family    config.AddressFamily
+	Stagger   time.Duration                                  // DefaultStagger if 0
+	Probe     func(ctx context.Context, conn net.Conn) error // Checks that the server answers on conn
+	// Called with the first probe error when no address answers, before falling back to the first address
+	OnNoAnswer func(err error)
+}
+
+type dialResult struct {
+	conn net.Conn
+	err  error
+}
+
+func (d *Dialer) Dial(ctx context.Context, host string, port string) (net.Conn, error) {
+	stagger := d.Stagger
+	if stagger <= 0 {
+		stagger = DefaultStagger
+	}
+
+	if d.Transport == config.TCP {
+		network, address := d.Family.Network("tcp"), net.JoinHostPort(host, port)
+		nd := net.Dialer{FallbackDelay: stagger}
+		dial := func() (net.Conn, error) { return nd.Dial(network, address) }
+		return TransportConn(NewTCPTransport(dial, hostAddr{network: network, address: address})), nil
+	}
+	if d.Transport != config.UDP {
+		return nil, fmt.Errorf("cannot dial hosts over the %s transport", d.Transport)
+	}
+
+	addrs, err := d.resolve(ctx, host, port)
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) == 1 {
+		return dialUDP(addrs[0])
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	results := make(chan dialResult, len(addrs))
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	next, running := 0, 0
+	var firstErr error
+	for {
+		select {
+		case <-timer.C:
+			go d.attempt(ctx, addrs[next], results)
+			next++
+			running++
+			if next < len(addrs) {
+				timer.Reset(stagger)
+			}
+
+		case r := <-results:
+			running--
+			if r.err == nil {
+				// Attempts still running see ctx cancelled, any that succeed regardless are closed
+				go func(running int) {
+					for ; running > 0; running-- {
+						if r := <-results; r.conn != nil {
+							r.conn.Close()
+						}
+					}
+				}(running)
+				return r.conn, nil
+			}
+
+			if firstErr == nil {
+				firstErr = r.err
+			}
+			if next < len(addrs) {
+				// Try the next address straight away rather than waiting out the stagger
+				if !timer.Stop() {
+					<-timer.C
+				}
+				timer.Reset(0)
+			} else if running == 0 {
+				if d.OnNoAnswer != nil {
+					d.OnNoAnswer(firstErr)
+				}
+				return dialUDP(addrs[0])
+			}
+
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (d *Dialer) attempt(ctx context.Context, addr *net.UDPAddr, results chan<- dialResult) {
+	conn, err := dialUDP(addr)
+	if err != nil {
+		results <- dialResult{err: err}
+		return
+	}
+	if d.Probe == nil {
+		results <- dialResult{conn: conn}
+		return
+	}
+
+	if err := d.Probe(ctx, conn); err != nil {
+		conn.Close()
+		results <- dialResult{err: fmt.Errorf("%s: %w", addr, err)}
+		return
+	}
+	results <- dialResult{conn: conn}
+}
+
+func dialUDP(addr *net.UDPAddr) (net.Conn, error) {
+	network := "udp6"
+	if addr.IP.To4() != nil {
+		network = "udp4"
+	}
+	conn, err := net.DialUDP(network, nil, addr)
+	if err != nil {
+		return nil, err
+	}
+	return TransportConn(NewUDPTransport(conn)), nil
+}
+
+// Resolves host to the addresses of the family, interleaving families and starting with the one listed first
+func (d *Dialer) resolve(ctx context.Context, host string, port string) ([]*net.UDPAddr, error) {
+	portNum, err := net.DefaultResolver.LookupPort(ctx, "udp", port)
+	if err != nil {
+		return nil, err
+	}
+	ips, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	var first, second []*net.UDPAddr
+	for _, ip := range ips {
+		if !d.Family.Allows(ip.IP) {
+			continue
+		}
+		addr := &net.UDPAddr{IP: ip.IP, Port: portNum, Zone: ip.Zone}
+		if len(first) == 0 || (ip.IP.To4() != nil) == (first[0].IP.To4() != nil) {
+			first = append(first, addr)
+		} else {
+			second = append(second, addr)
+		}
+	}
+	if len(first) == 0 {
+		return nil, fmt.Errorf("%s has no %s address", host, d.Family)
+	}
+
+	addrs := make([]*net.UDPAddr, 0, len(first)+len(second))
+	for i := 0; i < len(first) || i < len(second); i++ {
+		if i < len(first) {
+			addrs = append(addrs, first[i])
+		}
+		if i < len(second) {
+			addrs = append(addrs, second[i])
+		}
+	}
+	return addrs, nil
+}
@@ -0,0 +1,324 @@
+package transport
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	// Largest datagram sent by fragmentation, messages that fit are sent as-is. The checksum below adds
+	// ChecksumHeader bytes on the wire.
+	MaxDatagramSize = 1024
+	// Largest message that can be reassembled
+	MaxMessageSize = 1 << 16
+
+	fragmentMagic  byte = 0xF7 // Never the first byte of an encoded message
+	fragmentData   byte = 1
+	fragmentNack   byte = 2
+	fragmentHeader      = 10
+	maxFragments        = (MaxMessageSize + MaxDatagramSize - fragmentHeader - 1) / (MaxDatagramSize - fragmentHeader)
+)
+
+// ReassemblyError is returned when fragments of a message are still missing after all NACKs
+type ReassemblyError struct {
+	MsgID    uint32
+	From     net.Addr
+	Received int
+	Total    int
+}
+
+func (e *ReassemblyError) Error() string {
+	return fmt.Sprintf("failed to reassemble message %d from %s: received %d of %d fragments", e.MsgID, e.From, e.Received, e.Total)
+}
+
+// FragmentConn splits messages larger than MaxDatagramSize into numbered fragments and reassembles them.
+/*
+	Fragment:	[magic (8-bit)][type (8-bit)][msgID (32-bit)][index (16-bit)][count (16-bit)][payload]
+	NACK:		[magic (8-bit)][type (8-bit)][msgID (32-bit)][#missing (16-bit)][padding (16-bit)][missing indices (16-bit each)]
+
+	- A partial message that receives nothing for NackInterval is NACKed with its missing indices,
+	and the sender retransmits only those from the fragments it retains for RetainFor
+	- After MaxNacks unanswered NACKs the partial message is dropped and a ReassemblyError is returned
+*/
+type FragmentConn struct {
+	net.PacketConn
+	NackInterval time.Duration
+	MaxNacks     int
+	RetainFor    time.Duration
+
+	writeMu   sync.Mutex
+	nextMsgID uint32
+	sent      map[string]*sentMessage
+
+	mu           sync.Mutex
+	readDeadline time.Time
+	partials     map[string]*partialMessage
+	readBuf      []byte
+}
+
+type sentMessage struct {
+	fragments [][]byte
+	expires   time.Time
+}
+
+type partialMessage struct {
+	msgID        uint32
+	from         net.Addr
+	fragments    [][]byte
+	received     int
+	lastActivity time.Time
+	nacks        int
+}
+
+func NewFragmentConn(pc net.PacketConn) *FragmentConn {
+	return &FragmentConn{
+		PacketConn:   pc,
+		NackInterval: 200 * time.Millisecond,
+		MaxNacks:     3,
+		RetainFor:    10 * time.Second,
+		sent:         map[string]*sentMessage{},
+		partials:     map[string]*partialMessage{},
+		readBuf:      make([]byte, MaxMessageSize),
+	}
+}
+
+func (fc *FragmentConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	if len(b) <= MaxDatagramSize {
+		return fc.PacketConn.WriteTo(b, addr)
+	}
+	if len(b) > MaxMessageSize {
+		return 0, fmt.Errorf("message of %d bytes exceeds the maximum of %d", len(b), MaxMessageSize)
+	}
+
+	fc.writeMu.Lock()
+	fc.nextMsgID++
+	msgID := fc.nextMsgID
+	fragments := split(msgID, b)
+	now := time.Now()
+	for key, msg := range fc.sent {
+		if now.After(msg.expires) {
+			delete(fc.sent, key)
+		}
+	}
+	fc.sent[messageKey(addr, msgID)] = &sentMessage{fragments: fragments, expires: now.Add(fc.RetainFor)}
+	fc.writeMu.Unlock()
+
+	for _, fragment := range fragments {
+		if _, err := fc.PacketConn.WriteTo(fragment, addr); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+func (fc *FragmentConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	for {
+		// Wake up for whichever comes first, the caller's deadline or the next NACK
+		deadline := fc.readDeadline
+		if next := fc.nextNackTime(); !next.IsZero() && (deadline.IsZero() || next.Before(deadline)) {
+			deadline = next
+		}
+		if err := fc.PacketConn.SetReadDeadline(deadline); err != nil {
+			return 0, nil, err
+		}
+
+		// Unlocked while blocked so that deadlines can be changed meanwhile
+		fc.mu.Unlock()
+		n, addr, err := fc.PacketConn.ReadFrom(fc.readBuf)
+		fc.mu.Lock()
+
+		if err != nil {
+			var netErr net.Error
+			userDeadline := fc.readDeadline
+			if errors.As(err, &netErr) && netErr.Timeout() && (userDeadline.IsZero() || time.Now().Before(userDeadline)) {
+				if err := fc.nackStalled(); err != nil {
+					return 0, nil, err
+				}
+				continue
+			}
+			return 0, addr, err
+		}
+
+		data := fc.readBuf[:n]
+		if n < fragmentHeader || data[0] != fragmentMagic {
+			if n > len(b) {
+				return 0, addr, io.ErrShortBuffer
+			}
+			return copy(b, data), addr, nil
+		}
+
+		switch data[1] {
+		case fragmentData:
+			message, err := fc.addFragment(data, addr)
+			if err != nil || message == nil {
+				continue // Malformed fragments are treated as loss
+			}
+			if len(message) > len(b) {
+				return 0, addr, io.ErrShortBuffer
+			}
+			return copy(b, message), addr, nil
+		case fragmentNack:
+			fc.resend(data, addr)
+		}
+	}
+}
+
+func (fc *FragmentConn) SetDeadline(t time.Time) error {
+	if err := fc.SetReadDeadline(t); err != nil {
+		return err
+	}
+	return fc.PacketConn.SetWriteDeadline(t)
+}
+
+func (fc *FragmentConn) SetReadDeadline(t time.Time) error {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	fc.readDeadline = t
+	return fc.PacketConn.SetReadDeadline(t)
+}
+
+// Returns the reassembled message once the last fragment arrives
+func (fc *FragmentConn) addFragment(data []byte, addr net.Addr) ([]byte, error) {
+	// Callers must hold fc.mu
+	msgID := binary.BigEndian.Uint32(data[2:6])
+	index := int(binary.BigEndian.Uint16(data[6:8]))
+	count := int(binary.BigEndian.Uint16(data[8:10]))
+	if count == 0 || count > maxFragments || index >= count {
+		return nil, errors.New("invalid fragment header")
+	}
+
+	key := messageKey(addr, msgID)
+	partial, ok := fc.partials[key]
+	if !ok {
+		partial = &partialMessage{msgID: msgID, from: addr, fragments: make([][]byte, count)}
+		fc.partials[key] = partial
+	}
+	if len(partial.fragments) != count {
+		return nil, errors.New("inconsistent fragment count")
+	}
+
+	partial.lastActivity = time.Now()
+	if partial.fragments[index] != nil {
+		return nil, nil // Duplicate
+	}
+	partial.fragments[index] = append([]byte{}, data[fragmentHeader:]...)
+	partial.received++
+	if partial.received < count {
+		return nil, nil
+	}
+
+	delete(fc.partials, key)
+	message := []byte{}
+	for _, fragment := range partial.fragments {
+		message = append(message, fragment...)
+	}
+	return message, nil
+}
+
+// NACKs partial messages that have stalled, returning an error for those that ran out of NACKs
+func (fc *FragmentConn) nackStalled() error {
+	// Callers must hold fc.mu
+	now := time.Now()
+	for key, partial := range fc.partials {
+		if now.Before(partial.lastActivity.Add(fc.NackInterval)) {
+			continue
+		}
+
+		if partial.nacks >= fc.MaxNacks {
+			delete(fc.partials, key)
+			return &ReassemblyError{MsgID: partial.msgID, From: partial.from, Received: partial.received, Total: len(partial.fragments)}
+		}
+
+		partial.nacks++
+		partial.lastActivity = now
+		fc.PacketConn.WriteTo(nack(partial), partial.from)
+	}
+	return nil
+}
+
+func (fc *FragmentConn) nextNackTime() time.Time {
+	// Callers must hold fc.mu
+	var next time.Time
+	for _, partial := range fc.partials {
+		t := partial.lastActivity.Add(fc.NackInterval)
+		if next.IsZero() || t.Before(next) {
+			next = t
+		}
+	}
+	return next
+}
+
+// Retransmits the fragments listed in a NACK
+func (fc *FragmentConn) resend(data []byte, addr net.Addr) {
+	msgID := binary.BigEndian.Uint32(data[2:6])
+	numMissing := int(binary.BigEndian.Uint16(data[6:8]))
+	if len(data) < fragmentHeader+2*numMissing {
+		return
+	}
+
+	fc.writeMu.Lock()
+	msg, ok := fc.sent[messageKey(addr, msgID)]
+	fc.writeMu.Unlock()
+	if !ok {
+		return
+	}
+
+	for i := 0; i < numMissing; i++ {
+		index := int(binary.BigEndian.Uint16(data[fragmentHeader+2*i:]))
+		if index < len(msg.fragments) {
+			fc.PacketConn.WriteTo(msg.fragments[index], addr)
+		}
+	}
+}
+
+func split(msgID uint32, b []byte) [][]byte {
+	payloadSize := MaxDatagramSize - fragmentHeader
+	count := (len(b) + payloadSize - 1) / payloadSize
+	fragments := make([][]byte, 0, count)
+	for index := 0; index < count; index++ {
+		end := (index + 1) * payloadSize
+		if end > len(b) {
+			end = len(b)
+		}
+
+		fragment := make([]byte, fragmentHeader, fragmentHeader+end-index*payloadSize)
+		fragment[0] = fragmentMagic
+		fragment[1] = fragmentData
+		binary.BigEndian.PutUint32(fragment[2:6], msgID)
+		binary.BigEndian.PutUint16(fragment[6:8], uint16(index))
+		binary.BigEndian.PutUint16(fragment[8:10], uint16(count))
+		fragments = append(fragments, append(fragment, b[index*payloadSize:end]...))
+	}
+	return fragments
+}
+
+func nack(partial *partialMessage) []byte {
+	missing := []uint16{}
+	for index, fragment := range partial.fragments {
+		if fragment == nil {
+			missing = append(missing, uint16(index))
+		}
+	}
+
+	data := make([]byte, fragmentHeader, fragmentHeader+2*len(missing))
+	data[0] = fragmentMagic
+	data[1] = fragmentNack
+	binary.BigEndian.PutUint32(data[2:6], partial.msgID)
+	binary.BigEndian.PutUint16(data[6:8], uint16(len(missing)))
+	for _, index := range missing {
+		data = append(data, byte(index>>8), byte(index))
+	}
+	return data
+}
+
+func messageKey(addr net.Addr, msgID uint32) string {
+	return fmt.Sprintf("%s/%d", addr, msgID)
+}
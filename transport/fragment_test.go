@@ -0,0 +1,268 @@
+package transport
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Drops the datagrams written that drop returns true for, and counts the NACKs written
+type filterConn struct {
+	net.PacketConn
+	drop func(datagram []byte) bool
+
+	mu    sync.Mutex
+	nacks int
+}
+
+func (c *filterConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	c.mu.Lock()
+	if len(b) >= fragmentHeader && b[0] == fragmentMagic && b[1] == fragmentNack {
+		c.nacks++
+	}
+	drop := c.drop != nil && c.drop(b)
+	c.mu.Unlock()
+	if drop {
+		return len(b), nil
+	}
+	return c.PacketConn.WriteTo(b, addr)
+}
+
+func (c *filterConn) nackCount() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.nacks
+}
+
+func listenLoopback(t *testing.T) net.PacketConn {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { pc.Close() })
+	return pc
+}
+
+// A sender and receiver on loopback, with what each writes passing through a filterConn
+func fragmentPair(t *testing.T) (sender *FragmentConn, senderFilter *filterConn, receiver *FragmentConn, receiverFilter *filterConn) {
+	t.Helper()
+	senderFilter = &filterConn{PacketConn: listenLoopback(t)}
+	receiverFilter = &filterConn{PacketConn: listenLoopback(t)}
+	sender = NewFragmentConn(senderFilter)
+	receiver = NewFragmentConn(receiverFilter)
+	receiver.NackInterval = 20 * time.Millisecond
+	return sender, senderFilter, receiver, receiverFilter
+}
+
+// The sender only answers NACKs while reading
+func serveNacks(t *testing.T, fc *FragmentConn) {
+	t.Helper()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		buf := make([]byte, MaxMessageSize)
+		for {
+			if _, _, err := fc.ReadFrom(buf); errors.Is(err, net.ErrClosed) {
+				return
+			}
+		}
+	}()
+	t.Cleanup(func() {
+		fc.Close()
+		<-done
+	})
+}
+
+func randomMessage(size int) []byte {
+	message := make([]byte, size)
+	rand.New(rand.NewSource(int64(size))).Read(message)
+	message[0] = 0 // Never fragmentMagic, as for encoded messages
+	return message
+}
+
+func fragmentIndex(datagram []byte) int {
+	if len(datagram) < fragmentHeader || datagram[0] != fragmentMagic || datagram[1] != fragmentData {
+		return -1
+	}
+	return int(binary.BigEndian.Uint16(datagram[6:8]))
+}
+
+func receive(t *testing.T, fc *FragmentConn) []byte {
+	t.Helper()
+	fc.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, MaxMessageSize)
+	n, _, err := fc.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf[:n]
+}
+
+func TestFragmentReassembly(t *testing.T) {
+	for _, size := range []int{1, MaxDatagramSize, MaxDatagramSize + 1, 5000, MaxMessageSize} {
+		sender, _, receiver, _ := fragmentPair(t)
+		message := randomMessage(size)
+		if _, err := sender.WriteTo(message, receiver.LocalAddr()); err != nil {
+			t.Fatalf("%d bytes: %s", size, err)
+		}
+		if got := receive(t, receiver); !bytes.Equal(got, message) {
+			t.Fatalf("%d bytes: reassembled %d bytes that differ from the message", size, len(got))
+		}
+	}
+}
+
+func TestFragmentOnlyLargeMessages(t *testing.T) {
+	if got := split(1, randomMessage(MaxDatagramSize+1)); len(got) != 2 {
+		t.Fatalf("%d bytes split into %d fragments, want 2", MaxDatagramSize+1, len(got))
+	}
+	for _, fragment := range split(1, randomMessage(MaxMessageSize)) {
+		if len(fragment) > MaxDatagramSize {
+			t.Fatalf("fragment of %d bytes exceeds MaxDatagramSize", len(fragment))
+		}
+	}
+
+	// Messages that fit are sent without a fragment header
+	sender, _, receiver, _ := fragmentPair(t)
+	message := randomMessage(MaxDatagramSize)
+	if _, err := sender.WriteTo(message, receiver.LocalAddr()); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, MaxMessageSize)
+	receiver.PacketConn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := receiver.PacketConn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf[:n], message) {
+		t.Fatal("message that fits a datagram was not sent as-is")
+	}
+}
+
+func TestFragmentReordered(t *testing.T) {
+	_, _, receiver, _ := fragmentPair(t)
+	raw := listenLoopback(t)
+	message := randomMessage(5000)
+	fragments := split(7, message)
+
+	// Last to first, with a duplicate of the middle fragment
+	order := []int{}
+	for i := len(fragments) - 1; i >= 0; i-- {
+		order = append(order, i)
+	}
+	order = append(order[:2], append([]int{len(fragments) / 2}, order[2:]...)...)
+	for _, i := range order {
+		if _, err := raw.WriteTo(fragments[i], receiver.LocalAddr()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := receive(t, receiver); !bytes.Equal(got, message) {
+		t.Fatal("reordered fragments reassembled into a different message")
+	}
+}
+
+func TestFragmentMissingIsNacked(t *testing.T) {
+	sender, senderFilter, receiver, receiverFilter := fragmentPair(t)
+	serveNacks(t, sender)
+
+	// Only the first transmission of fragments 1 and 3 is lost
+	dropped := map[int]bool{}
+	senderFilter.drop = func(datagram []byte) bool {
+		index := fragmentIndex(datagram)
+		if (index == 1 || index == 3) && !dropped[index] {
+			dropped[index] = true
+			return true
+		}
+		return false
+	}
+
+	message := randomMessage(5000)
+	if _, err := sender.WriteTo(message, receiver.LocalAddr()); err != nil {
+		t.Fatal(err)
+	}
+	if got := receive(t, receiver); !bytes.Equal(got, message) {
+		t.Fatal("message reassembled after a NACK differs")
+	}
+	if receiverFilter.nackCount() == 0 {
+		t.Fatal("message was reassembled without a NACK")
+	}
+}
+
+func TestFragmentNackListsMissing(t *testing.T) {
+	partial := &partialMessage{msgID: 9, fragments: [][]byte{{1}, nil, {3}, nil, nil}}
+	data := nack(partial)
+	if data[0] != fragmentMagic || data[1] != fragmentNack || binary.BigEndian.Uint32(data[2:6]) != 9 {
+		t.Fatalf("malformed NACK header % x", data[:fragmentHeader])
+	}
+	if got := binary.BigEndian.Uint16(data[6:8]); got != 3 {
+		t.Fatalf("NACK lists %d missing fragments, want 3", got)
+	}
+	want := []byte{0, 1, 0, 3, 0, 4}
+	if got := data[fragmentHeader:]; !bytes.Equal(got, want) {
+		t.Fatalf("NACK lists indices % x, want % x", got, want)
+	}
+}
+
+func TestFragmentGivesUpAfterMaxNacks(t *testing.T) {
+	sender, senderFilter, receiver, receiverFilter := fragmentPair(t)
+	serveNacks(t, sender)
+	senderFilter.drop = func(datagram []byte) bool { return fragmentIndex(datagram) == 2 }
+
+	message := randomMessage(5000)
+	if _, err := sender.WriteTo(message, receiver.LocalAddr()); err != nil {
+		t.Fatal(err)
+	}
+	receiver.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, _, err := receiver.ReadFrom(make([]byte, MaxMessageSize))
+
+	var reassemblyErr *ReassemblyError
+	if !errors.As(err, &reassemblyErr) {
+		t.Fatalf("got error %v, want a ReassemblyError", err)
+	}
+	total := len(split(1, message))
+	if reassemblyErr.Received != total-1 || reassemblyErr.Total != total {
+		t.Fatalf("error reports %d of %d fragments, want %d of %d", reassemblyErr.Received, reassemblyErr.Total, total-1, total)
+	}
+	if nacks := receiverFilter.nackCount(); nacks != receiver.MaxNacks {
+		t.Fatalf("receiver sent %d NACKs, want %d", nacks, receiver.MaxNacks)
+	}
+}
+
+func TestFragmentSizeLimits(t *testing.T) {
+	sender, _, receiver, _ := fragmentPair(t)
+	if _, err := sender.WriteTo(randomMessage(MaxMessageSize+1), receiver.LocalAddr()); err == nil {
+		t.Fatal("message larger than MaxMessageSize was sent")
+	}
+
+	// A reassembled message larger than the caller's buffer is not truncated
+	if _, err := sender.WriteTo(randomMessage(5000), receiver.LocalAddr()); err != nil {
+		t.Fatal(err)
+	}
+	receiver.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, _, err := receiver.ReadFrom(make([]byte, 4999)); !errors.Is(err, io.ErrShortBuffer) {
+		t.Fatalf("got error %v reading into a short buffer, want io.ErrShortBuffer", err)
+	}
+
+	// Headers claiming more fragments than a message can have are rejected before anything is allocated
+	fragment := split(1, randomMessage(MaxDatagramSize+1))[0]
+	binary.BigEndian.PutUint16(fragment[8:10], maxFragments+1)
+	if _, err := receiver.addFragment(fragment, sender.LocalAddr()); err == nil {
+		t.Fatal("fragment claiming too many fragments was accepted")
+	}
+	binary.BigEndian.PutUint16(fragment[6:8], 4)
+	binary.BigEndian.PutUint16(fragment[8:10], 5)
+	if _, err := receiver.addFragment(fragment, sender.LocalAddr()); err != nil {
+		t.Fatal(err)
+	}
+	binary.BigEndian.PutUint16(fragment[6:8], 5)
+	if _, err := receiver.addFragment(fragment, sender.LocalAddr()); err == nil {
+		t.Fatal("fragment index beyond its count was accepted")
+	}
+}
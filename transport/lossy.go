@@ -0,0 +1,261 @@
+package transport
+
+import (
+	"errors"
+	"math/rand"
+	"net"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/chiahsoon/cz4013-client/config"
+)
+
+// LossyConn wraps the client's connection to the server and simulates an unreliable network in both directions.
+/*
+	- Requests are affected when written and replies when read, each with their own drop probability
+	- A reordered datagram is held back and only delivered after the next datagram in the same direction,
+	  duplicates included
+	- Every datagram is delayed on its own, a slow reply does not hold up the replies read after it. Delayed
+	  replies still respect the read deadline, so slow replies look like timeouts to the caller.
+	- Corruption flips a single random bit, in either direction
+	- Requests reaching the connection uncorrupted are counted by the label set when they were written, so
+	  the executions a server should perform can be derived from the faults injected
+*/
+type LossyConn struct {
+	net.Conn
+	sim config.LossSimulation
+	// Called with the error of a delayed request, which Write has already returned from
+	OnSendError func(err error)
+
+	mu           sync.Mutex
+	rng          *rand.Rand
+	readDeadline time.Time
+	replies      []delayedReply // Replies read from the socket, ordered by when they are delivered
+	heldRequests []lossyRequest // Copies of the request held back
+	heldReply    []byte
+	label        int
+	delivered    map[int]int // Requests passed on uncorrupted, by label
+}
+
+type lossyRequest struct {
+	data   []byte
+	label  int
+	intact bool
+}
+
+type delayedReply struct {
+	due  time.Time
+	data []byte
+}
+
+func NewLossyConn(conn net.Conn, sim config.LossSimulation) *LossyConn {
+	return &LossyConn{
+		Conn:      conn,
+		sim:       sim,
+		rng:       rand.New(rand.NewSource(sim.Seed)),
+		delivered: map[int]int{},
+	}
+}
+
+// Label tags the requests written from now on, e.g. with the operation they are for
+func (lc *LossyConn) Label(label int) {
+	lc.mu.Lock()
+	lc.label = label
+	lc.mu.Unlock()
+}
+
+// Delivered returns how many requests with each label were passed on uncorrupted so far. Delayed requests are
+// only counted once sent.
+func (lc *LossyConn) Delivered() map[int]int {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	delivered := make(map[int]int, len(lc.delivered))
+	for label, n := range lc.delivered {
+		delivered[label] = n
+	}
+	return delivered
+}
+
+// Flush sends the request held back to be reordered, which otherwise waits for the next write
+func (lc *LossyConn) Flush() error {
+	lc.mu.Lock()
+	requests := lc.heldRequests
+	lc.heldRequests = nil
+	lc.mu.Unlock()
+	return lc.send(requests)
+}
+
+func (lc *LossyConn) Write(b []byte) (int, error) {
+	data := append([]byte{}, b...)
+
+	lc.mu.Lock()
+	if lc.chance(lc.sim.DropRequest) {
+		lc.mu.Unlock()
+		return len(b), nil
+	}
+
+	request := lossyRequest{data: data, label: lc.label, intact: !lc.corrupt(data)}
+	requests := []lossyRequest{request}
+	if lc.chance(lc.sim.Duplicate) {
+		requests = append(requests, request)
+	}
+
+	if lc.heldRequests == nil && lc.chance(lc.sim.Reorder) {
+		lc.heldRequests = requests
+		lc.mu.Unlock()
+		return len(b), nil
+	}
+
+	requests = append(requests, lc.heldRequests...)
+	lc.heldRequests = nil
+	delay := lc.delay()
+	lc.mu.Unlock()
+
+	if delay > 0 {
+		time.AfterFunc(delay, func() {
+			if err := lc.send(requests); err != nil && lc.OnSendError != nil {
+				lc.OnSendError(err)
+			}
+		})
+		return len(b), nil
+	}
+
+	if err := lc.send(requests); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+func (lc *LossyConn) send(requests []lossyRequest) error {
+	for _, request := range requests {
+		if _, err := lc.Conn.Write(request.data); err != nil {
+			return err
+		}
+		if request.intact {
+			lc.mu.Lock()
+			lc.delivered[request.label]++
+			lc.mu.Unlock()
+		}
+	}
+	return nil
+}
+
+func (lc *LossyConn) Read(b []byte) (int, error) {
+	for {
+		lc.mu.Lock()
+		now := time.Now()
+		if len(lc.replies) > 0 && !lc.replies[0].due.After(now) {
+			data := lc.replies[0].data
+			lc.replies = lc.replies[1:]
+			lc.mu.Unlock()
+			return copy(b, data), nil
+		}
+		if !lc.readDeadline.IsZero() && !lc.readDeadline.After(now) {
+			// Replies due later are kept for the next read
+			lc.mu.Unlock()
+			return 0, os.ErrDeadlineExceeded
+		}
+		// Stop reading the socket when the next delayed reply is due
+		err := lc.Conn.SetReadDeadline(lc.wake())
+		lc.mu.Unlock()
+		if err != nil {
+			return 0, err
+		}
+
+		n, err := lc.Conn.Read(b)
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			continue
+		}
+		if err != nil {
+			return n, err
+		}
+		data := append([]byte{}, b[:n]...)
+
+		lc.mu.Lock()
+		if lc.chance(lc.sim.DropReply) {
+			lc.mu.Unlock()
+			continue
+		}
+		lc.corrupt(data)
+
+		if lc.heldReply == nil && lc.chance(lc.sim.Reorder) {
+			lc.heldReply = data
+			lc.mu.Unlock()
+			continue
+		}
+
+		// Duplicates and a reply held back arrive right after the reply
+		due := time.Now().Add(lc.delay())
+		lc.schedule(due, data)
+		if lc.chance(lc.sim.Duplicate) {
+			lc.schedule(due, data)
+		}
+		if lc.heldReply != nil {
+			lc.schedule(due, lc.heldReply)
+			lc.heldReply = nil
+		}
+		lc.mu.Unlock()
+	}
+}
+
+// Queues data for delivery at due, after replies due at the same time. Callers must hold lc.mu.
+func (lc *LossyConn) schedule(due time.Time, data []byte) {
+	i := sort.Search(len(lc.replies), func(i int) bool { return lc.replies[i].due.After(due) })
+	lc.replies = append(lc.replies, delayedReply{})
+	copy(lc.replies[i+1:], lc.replies[i:])
+	lc.replies[i] = delayedReply{due: due, data: data}
+}
+
+// The earlier of the read deadline and when the next delayed reply is due, zero if neither is set. Callers must
+// hold lc.mu.
+func (lc *LossyConn) wake() time.Time {
+	wake := lc.readDeadline
+	if len(lc.replies) > 0 && (wake.IsZero() || lc.replies[0].due.Before(wake)) {
+		wake = lc.replies[0].due
+	}
+	return wake
+}
+
+func (lc *LossyConn) SetDeadline(t time.Time) error {
+	if err := lc.SetReadDeadline(t); err != nil {
+		return err
+	}
+	return lc.Conn.SetWriteDeadline(t)
+}
+
+func (lc *LossyConn) SetReadDeadline(t time.Time) error {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	lc.readDeadline = t
+	return lc.Conn.SetReadDeadline(lc.wake())
+}
+
+func (lc *LossyConn) chance(p float64) bool {
+	// Callers must hold lc.mu
+	return p > 0 && lc.rng.Float64() < p
+}
+
+// Reports whether a bit was flipped
+func (lc *LossyConn) corrupt(data []byte) bool {
+	// Callers must hold lc.mu
+	if len(data) == 0 || !lc.chance(lc.sim.Corrupt) {
+		return false
+	}
+	bit := lc.rng.Intn(len(data) * 8)
+	data[bit/8] ^= 1 << uint(bit%8)
+	return true
+}
+
+func (lc *LossyConn) delay() time.Duration {
+	// Callers must hold lc.mu
+	delay := lc.sim.Delay
+	if lc.sim.Jitter > 0 {
+		delay += time.Duration(lc.rng.Int63n(int64(2*lc.sim.Jitter)+1)) - lc.sim.Jitter
+	}
+	if delay < 0 {
+		return 0
+	}
+	return delay
+}
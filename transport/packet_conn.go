@@ -0,0 +1,49 @@
+package transport
+
+import (
+	"net"
+)
+
+// Layers such as fragmentation are written once against net.PacketConn, so that the client's
+// connected net.Conn and the server's unconnected net.PacketConn can share them.
+
+// ConnPacketConn adapts a connected conn, every datagram is from and to its remote address
+func ConnPacketConn(conn net.Conn) net.PacketConn {
+	return &connectedPacketConn{conn}
+}
+
+type connectedPacketConn struct {
+	net.Conn
+}
+
+func (c *connectedPacketConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	n, err := c.Conn.Read(b)
+	return n, c.Conn.RemoteAddr(), err
+}
+
+func (c *connectedPacketConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	return c.Conn.Write(b)
+}
+
+// PacketConnConn adapts pc into a conn that writes to raddr
+func PacketConnConn(pc net.PacketConn, raddr net.Addr) net.Conn {
+	return &boundConn{PacketConn: pc, raddr: raddr}
+}
+
+type boundConn struct {
+	net.PacketConn
+	raddr net.Addr
+}
+
+func (c *boundConn) Read(b []byte) (int, error) {
+	n, _, err := c.PacketConn.ReadFrom(b)
+	return n, err
+}
+
+func (c *boundConn) Write(b []byte) (int, error) {
+	return c.PacketConn.WriteTo(b, c.raddr)
+}
+
+func (c *boundConn) RemoteAddr() net.Addr {
+	return c.raddr
+}
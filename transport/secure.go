@@ -0,0 +1,494 @@
+package transport
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	secureHello      byte = 0xA1
+	secureHelloReply byte = 0xA2
+	secureData       byte = 0xA3
+
+	keySize           = 32 // X25519 public keys
+	sessionIDSize     = 8
+	secureDataHeader  = 1 + sessionIDSize + 8 // Type, session ID and counter
+	secureHelloSize   = 1 + keySize
+	secureReplySize   = 1 + 2*keySize + sessionIDSize
+	replayWindowSize  = 64
+	handshakeTimeout  = time.Second
+	handshakeAttempts = 5
+	sessionIdleExpiry = 30 * time.Minute
+	maxSessions       = 4096
+
+	// Sessions that have not yet received a sealed datagram, which anyone can create with a hello, only live
+	// as long as a client keeps retrying the handshake
+	pendingSessionExpiry = 2 * handshakeTimeout * handshakeAttempts
+
+	// Bytes added to every datagram by the secure channel, on top of MaxDatagramSize
+	SecureOverhead = secureDataHeader + 16
+)
+
+var secureInfo = []byte("cz4013 secure channel v1")
+
+var errReplay = errors.New("replayed counter")
+
+// SecureError is returned for a datagram that could not be opened, which callers should treat as lost
+type SecureError struct {
+	From   net.Addr
+	Reason string
+	Replay bool // Authentic, but already received
+}
+
+func (e *SecureError) Error() string {
+	return fmt.Sprintf("rejected datagram from %s: %s", e.From, e.Reason)
+}
+
+// SecureConn seals every datagram with AES-GCM under keys agreed in a handshake.
+/*
+	Hello:	[type (8-bit)][client ephemeral key (256-bit)]
+	Reply:	[type (8-bit)][server ephemeral key (256-bit)][server static key (256-bit)][session ID (64-bit)]
+	Data:	[type (8-bit)][session ID (64-bit)][counter (64-bit)][sealed payload]
+
+	- Keys are derived with HKDF-SHA256 from X25519 of the client's ephemeral key with both server keys,
+	so only the holder of the static key can open requests. Clients may pin the static key.
+	- Each direction has its own key, the nonce is the sender's counter and the header is authenticated
+	- Counters below the 64 most recent, or already seen within them, are rejected as replays
+	- It sits below fragmentation, so every fragment and NACK is sealed separately
+	- Sessions no datagram was opened for expire quickly and give way to new hellos when the table is full,
+	so a flood of hellos cannot lock out clients that completed their handshake
+*/
+type SecureConn struct {
+	net.PacketConn
+	static *ecdh.PrivateKey // Server only
+
+	mu       sync.Mutex
+	session  *secureSession            // Client only
+	sessions map[uint64]*secureSession // Server only, by session ID
+	byAddr   map[string]*secureSession // Server only, where replies are sealed for
+	byHello  map[string]*secureSession // Server only, so a retransmitted hello gets the same reply
+
+	readMu  sync.Mutex
+	readBuf []byte
+}
+
+type secureSession struct {
+	id       uint64
+	send     cipher.AEAD
+	recv     cipher.AEAD
+	hello    string // Client ephemeral key
+	reply    []byte // Handshake reply, resent for duplicate hellos
+	addr     net.Addr
+	lastSeen time.Time
+	opened   bool // Whether a sealed datagram was received, proving the client holds the keys
+
+	mu      sync.Mutex
+	counter uint64
+	replay  replayWindow
+}
+
+// GenerateKey returns a new static key for a server
+func GenerateKey() (*ecdh.PrivateKey, error) {
+	return ecdh.X25519().GenerateKey(rand.Reader)
+}
+
+// ParseKey parses a hex encoded static private key, as written by FormatKey
+func ParseKey(s string) (*ecdh.PrivateKey, error) {
+	b, err := hex.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("invalid key: %w", err)
+	}
+	return ecdh.X25519().NewPrivateKey(b)
+}
+
+func FormatKey(key []byte) string {
+	return hex.EncodeToString(key)
+}
+
+// ListenSecure accepts handshakes from clients and seals replies for their sessions
+func ListenSecure(pc net.PacketConn, static *ecdh.PrivateKey) *SecureConn {
+	return &SecureConn{
+		PacketConn: pc,
+		static:     static,
+		sessions:   map[uint64]*secureSession{},
+		byAddr:     map[string]*secureSession{},
+		byHello:    map[string]*secureSession{},
+	}
+}
+
+// DialSecure performs the handshake with raddr before returning, and also returns the server's static key.
+// If pinned is not nil, servers presenting another static key are rejected.
+func DialSecure(pc net.PacketConn, raddr net.Addr, pinned []byte) (*SecureConn, []byte, error) {
+	ephemeral, err := GenerateKey()
+	if err != nil {
+		return nil, nil, err
+	}
+	hello := append([]byte{secureHello}, ephemeral.PublicKey().Bytes()...)
+
+	defer pc.SetReadDeadline(time.Time{})
+	buf := make([]byte, MaxDatagramSize)
+	lastErr := errors.New("no reply")
+	for attempt := 0; attempt < handshakeAttempts; attempt++ {
+		if _, err := pc.WriteTo(hello, raddr); err != nil {
+			return nil, nil, err
+		}
+
+		deadline := time.Now().Add(handshakeTimeout)
+		pc.SetReadDeadline(deadline)
+		for {
+			n, _, err := pc.ReadFrom(buf)
+			var checksumErr *ChecksumError
+			if errors.As(err, &checksumErr) {
+				continue
+			}
+			if err != nil {
+				var netErr net.Error
+				if !(errors.As(err, &netErr) && netErr.Timeout()) {
+					lastErr = err
+					time.Sleep(time.Until(deadline)) // Wait out the attempt, as for refused connections
+				}
+				break
+			}
+
+			reply := buf[:n]
+			if n != secureReplySize || reply[0] != secureHelloReply {
+				continue // Stray datagrams from before the handshake
+			}
+
+			serverStatic := reply[1+keySize : 1+2*keySize]
+			if pinned != nil && !bytes.Equal(pinned, serverStatic) {
+				return nil, nil, fmt.Errorf("server key %s does not match the pinned key", FormatKey(serverStatic))
+			}
+
+			session, err := clientSession(ephemeral, reply)
+			if err != nil {
+				return nil, nil, err
+			}
+			sc := &SecureConn{PacketConn: pc, session: session}
+			return sc, append([]byte{}, serverStatic...), nil
+		}
+	}
+	return nil, nil, fmt.Errorf("secure handshake failed after %d attempts: %w", handshakeAttempts, lastErr)
+}
+
+func clientSession(ephemeral *ecdh.PrivateKey, reply []byte) (*secureSession, error) {
+	serverEphemeral, err := ecdh.X25519().NewPublicKey(reply[1 : 1+keySize])
+	if err != nil {
+		return nil, err
+	}
+	serverStatic, err := ecdh.X25519().NewPublicKey(reply[1+keySize : 1+2*keySize])
+	if err != nil {
+		return nil, err
+	}
+
+	ee, err := ephemeral.ECDH(serverEphemeral)
+	if err != nil {
+		return nil, err
+	}
+	es, err := ephemeral.ECDH(serverStatic)
+	if err != nil {
+		return nil, err
+	}
+
+	toServer, toClient, err := deriveKeys(append(ee, es...), ephemeral.PublicKey().Bytes(), reply[1:1+2*keySize])
+	if err != nil {
+		return nil, err
+	}
+	return &secureSession{id: binary.BigEndian.Uint64(reply[1+2*keySize:]), send: toServer, recv: toClient}, nil
+}
+
+// Returns the AEADs sealing client to server and server to client datagrams
+func deriveKeys(secret, clientKey, serverKeys []byte) (cipher.AEAD, cipher.AEAD, error) {
+	info := append(append(append([]byte{}, secureInfo...), clientKey...), serverKeys...)
+	keys := make([]byte, 64)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, secret, nil, info), keys); err != nil {
+		return nil, nil, err
+	}
+	toServer, err := newAEAD(keys[:32])
+	if err != nil {
+		return nil, nil, err
+	}
+	toClient, err := newAEAD(keys[32:])
+	if err != nil {
+		return nil, nil, err
+	}
+	return toServer, toClient, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (sc *SecureConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	session := sc.session
+	if sc.static != nil {
+		sc.mu.Lock()
+		session = sc.byAddr[addr.String()]
+		sc.mu.Unlock()
+	}
+	if session == nil {
+		return 0, fmt.Errorf("no secure session with %s", addr)
+	}
+
+	if _, err := sc.PacketConn.WriteTo(session.seal(b), addr); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+func (sc *SecureConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	sc.readMu.Lock()
+	defer sc.readMu.Unlock()
+	if len(sc.readBuf) < len(b)+SecureOverhead {
+		sc.readBuf = make([]byte, len(b)+SecureOverhead)
+	}
+
+	for {
+		n, addr, err := sc.PacketConn.ReadFrom(sc.readBuf[:len(b)+SecureOverhead])
+		if err != nil {
+			return 0, addr, err
+		}
+
+		datagram := sc.readBuf[:n]
+		if sc.static != nil && n == secureHelloSize && datagram[0] == secureHello {
+			if err := sc.accept(datagram, addr); err != nil {
+				return 0, addr, &SecureError{From: addr, Reason: err.Error()}
+			}
+			continue
+		}
+
+		payload, err := sc.open(datagram, addr)
+		if err != nil {
+			return 0, addr, &SecureError{From: addr, Reason: err.Error(), Replay: errors.Is(err, errReplay)}
+		}
+		if len(payload) > len(b) {
+			return 0, addr, &SecureError{From: addr, Reason: "payload too large"}
+		}
+		return copy(b, payload), addr, nil
+	}
+}
+
+// Answers a client's hello, creating its session unless the hello is a retransmission
+func (sc *SecureConn) accept(hello []byte, addr net.Addr) error {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.expireSessions()
+
+	clientKey := string(hello[1:])
+	session, ok := sc.byHello[clientKey]
+	if !ok {
+		if len(sc.sessions) >= maxSessions && !sc.evictPending() {
+			return errors.New("too many secure sessions")
+		}
+
+		var err error
+		if session, err = sc.newServerSession(hello[1:]); err != nil {
+			return err
+		}
+		session.hello = clientKey
+		sc.sessions[session.id] = session
+		sc.byHello[clientKey] = session
+	}
+
+	session.addr = addr
+	session.lastSeen = time.Now()
+	if current := sc.byAddr[addr.String()]; current == nil || !current.opened {
+		// A hello may come from a spoofed address, so it does not take over an opened session's replies
+		sc.byAddr[addr.String()] = session
+	}
+	_, err := sc.PacketConn.WriteTo(session.reply, addr)
+	return err
+}
+
+func (sc *SecureConn) newServerSession(clientKey []byte) (*secureSession, error) {
+	client, err := ecdh.X25519().NewPublicKey(clientKey)
+	if err != nil {
+		return nil, err
+	}
+	ephemeral, err := GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+
+	ee, err := ephemeral.ECDH(client)
+	if err != nil {
+		return nil, err
+	}
+	es, err := sc.static.ECDH(client)
+	if err != nil {
+		return nil, err
+	}
+
+	serverKeys := append(ephemeral.PublicKey().Bytes(), sc.static.PublicKey().Bytes()...)
+	toServer, toClient, err := deriveKeys(append(ee, es...), clientKey, serverKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	id := make([]byte, sessionIDSize)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	reply := append(append([]byte{secureHelloReply}, serverKeys...), id...)
+	return &secureSession{id: binary.BigEndian.Uint64(id), send: toClient, recv: toServer, reply: reply}, nil
+}
+
+// Callers must hold sc.mu
+func (sc *SecureConn) expireSessions() {
+	now := time.Now()
+	for _, session := range sc.sessions {
+		expiry := sessionIdleExpiry
+		if !session.opened {
+			expiry = pendingSessionExpiry
+		}
+		if now.Sub(session.lastSeen) >= expiry {
+			sc.removeSession(session)
+		}
+	}
+}
+
+// Removes the least recently seen session no datagram was opened for, if any. Callers must hold sc.mu.
+func (sc *SecureConn) evictPending() bool {
+	var oldest *secureSession
+	for _, session := range sc.sessions {
+		if !session.opened && (oldest == nil || session.lastSeen.Before(oldest.lastSeen)) {
+			oldest = session
+		}
+	}
+	if oldest == nil {
+		return false
+	}
+	sc.removeSession(oldest)
+	return true
+}
+
+// Callers must hold sc.mu
+func (sc *SecureConn) removeSession(session *secureSession) {
+	delete(sc.sessions, session.id)
+	delete(sc.byHello, session.hello)
+	if sc.byAddr[session.addr.String()] == session {
+		delete(sc.byAddr, session.addr.String())
+	}
+}
+
+func (sc *SecureConn) open(datagram []byte, addr net.Addr) ([]byte, error) {
+	if len(datagram) < SecureOverhead || datagram[0] != secureData {
+		return nil, errors.New("not a sealed datagram")
+	}
+
+	id := binary.BigEndian.Uint64(datagram[1 : 1+sessionIDSize])
+	session := sc.session
+	if sc.static != nil {
+		sc.mu.Lock()
+		session = sc.sessions[id]
+		sc.mu.Unlock()
+	}
+	if session == nil || session.id != id {
+		return nil, errors.New("unknown session")
+	}
+
+	payload, err := session.open(datagram)
+	if err != nil {
+		return nil, err
+	}
+
+	if sc.static != nil {
+		// Replies follow the client if its address changes
+		sc.mu.Lock()
+		session.lastSeen = time.Now()
+		session.opened = true
+		if sc.byAddr[addr.String()] != session {
+			session.addr = addr
+			sc.byAddr[addr.String()] = session
+		}
+		sc.mu.Unlock()
+	}
+	return payload, nil
+}
+
+func (s *secureSession) seal(b []byte) []byte {
+	s.mu.Lock()
+	s.counter++
+	counter := s.counter
+	s.mu.Unlock()
+
+	header := make([]byte, secureDataHeader, secureDataHeader+len(b)+s.send.Overhead())
+	header[0] = secureData
+	binary.BigEndian.PutUint64(header[1:], s.id)
+	binary.BigEndian.PutUint64(header[1+sessionIDSize:], counter)
+	return s.send.Seal(header, nonce(counter), b, header)
+}
+
+func (s *secureSession) open(datagram []byte) ([]byte, error) {
+	header := datagram[:secureDataHeader]
+	counter := binary.BigEndian.Uint64(header[1+sessionIDSize:])
+	payload, err := s.recv.Open(nil, nonce(counter), datagram[secureDataHeader:], header)
+	if err != nil {
+		return nil, errors.New("authentication failed")
+	}
+
+	// Only authenticated counters move the window, so forged datagrams cannot block genuine ones
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.replay.accept(counter) {
+		return nil, fmt.Errorf("%w %d", errReplay, counter)
+	}
+	return payload, nil
+}
+
+func nonce(counter uint64) []byte {
+	n := make([]byte, 12)
+	binary.BigEndian.PutUint64(n[4:], counter)
+	return n
+}
+
+// Tracks the highest counter received and which of the replayWindowSize counters below it were seen.
+// Counters start from 1.
+type replayWindow struct {
+	highest uint64
+	seen    uint64 // Bit i is set if highest-i was received
+}
+
+func (w *replayWindow) accept(counter uint64) bool {
+	if counter == 0 {
+		return false
+	}
+
+	if counter > w.highest {
+		shift := counter - w.highest
+		if shift >= replayWindowSize {
+			w.seen = 0
+		} else {
+			w.seen <<= shift
+		}
+		w.seen |= 1
+		w.highest = counter
+		return true
+	}
+
+	diff := w.highest - counter
+	if diff >= replayWindowSize || w.seen&(1<<diff) != 0 {
+		return false
+	}
+	w.seen |= 1 << diff
+	return true
+}
@@ -0,0 +1,275 @@
+package transport_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdh"
+	"errors"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	apiModels "github.com/chiahsoon/cz4013-client/api/models"
+	"github.com/chiahsoon/cz4013-client/client"
+	"github.com/chiahsoon/cz4013-client/config"
+	"github.com/chiahsoon/cz4013-client/server"
+	"github.com/chiahsoon/cz4013-client/services"
+	"github.com/chiahsoon/cz4013-client/transport"
+)
+
+// Collects the server's log, which is where it reports the datagrams it drops
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// Runs the in-repo server on a socket of the given transport until the test ends, secure if key is not nil
+func startServer(t *testing.T, kind config.Transport, address string, key *ecdh.PrivateKey) (net.PacketConn, *syncBuffer) {
+	t.Helper()
+	pc, err := transport.Listen(kind, address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logs := &syncBuffer{}
+	srv := server.NewServer(server.NewBank())
+	srv.Key = key
+	srv.Logger = log.New(logs, "", 0)
+	go srv.Serve(pc)
+	t.Cleanup(func() { pc.Close() })
+	return pc, logs
+}
+
+func newClient(conn net.Conn) *client.Client {
+	return client.NewClient(conn, &services.ConnectionService{
+		InvocationSemantic: config.AtLeastOnce,
+		RetryPolicy:        &services.FixedBackoff{Interval: 200 * time.Millisecond},
+		MaxRetryCount:      5,
+	})
+}
+
+func openAccount(t *testing.T, c *client.Client, balance int64) {
+	t.Helper()
+	req := apiModels.OpenAccountReq{Name: "Alice Tan", Password: "hunter22", InitialBalance: apiModels.NewMoney(balance, "SGD")}
+	if _, err := c.OpenAccount(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func balance(t *testing.T, c *client.Client, account int) apiModels.Money {
+	t.Helper()
+	resp, err := c.GetBalance(context.Background(), apiModels.GetBalanceReq{AccountNumber: account, Name: "Alice Tan", Password: "hunter22", Currency: "SGD"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.Balance
+}
+
+// Records the datagrams written to a connection, so a test can replay them
+type recordingConn struct {
+	net.Conn
+	mu      sync.Mutex
+	written [][]byte
+}
+
+func (c *recordingConn) Write(b []byte) (int, error) {
+	c.mu.Lock()
+	c.written = append(c.written, append([]byte{}, b...))
+	c.mu.Unlock()
+	return c.Conn.Write(b)
+}
+
+func (c *recordingConn) take() [][]byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	written := c.written
+	c.written = nil
+	return written
+}
+
+func dialSecure(t *testing.T, addr net.Addr, pinned []byte) (*recordingConn, net.Conn, []byte, error) {
+	t.Helper()
+	raw, err := net.Dial("udp", addr.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { raw.Close() })
+	rec := &recordingConn{Conn: raw}
+	conn, serverKey, err := transport.NewSecureClientConn(rec, pinned)
+	return rec, conn, serverKey, err
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); !cond(); {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSecureChannelHandshake(t *testing.T) {
+	key, err := transport.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pc, _ := startServer(t, config.UDP, "127.0.0.1:0", key)
+
+	_, conn, serverKey, err := dialSecure(t, pc.LocalAddr(), key.PublicKey().Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(serverKey, key.PublicKey().Bytes()) {
+		t.Fatalf("server presented key %s, want %s", transport.FormatKey(serverKey), transport.FormatKey(key.PublicKey().Bytes()))
+	}
+
+	c := newClient(conn)
+	openAccount(t, c, 1000)
+	if got := balance(t, c, 1); got != apiModels.NewMoney(1000, "SGD") {
+		t.Fatalf("balance is %s, want 10.00 SGD", got)
+	}
+
+	// A client that does not pin the key learns it from the handshake
+	_, _, serverKey, err = dialSecure(t, pc.LocalAddr(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(serverKey, key.PublicKey().Bytes()) {
+		t.Fatalf("unpinned handshake returned key %s", transport.FormatKey(serverKey))
+	}
+}
+
+func TestSecureChannelRejectsReplay(t *testing.T) {
+	key, err := transport.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pc, logs := startServer(t, config.UDP, "127.0.0.1:0", key)
+
+	rec, conn, _, err := dialSecure(t, pc.LocalAddr(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := newClient(conn)
+	openAccount(t, c, 1000)
+
+	rec.take()
+	req := apiModels.UpdateBalanceReq{AccountNumber: 1, Name: "Alice Tan", Password: "hunter22", Amount: apiModels.NewMoney(250, "SGD")}
+	if _, err := c.Deposit(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+
+	// The at-least-once server would execute a retransmitted deposit again, so only the secure channel
+	// stands between a replayed datagram and a second deposit
+	sealed := rec.take()
+	for _, datagram := range sealed {
+		if _, err := rec.Conn.Write(datagram); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitFor(t, "the replay to be dropped", func() bool {
+		return strings.Count(logs.String(), "replayed counter") >= len(sealed)
+	})
+
+	if got := balance(t, c, 1); got != apiModels.NewMoney(1250, "SGD") {
+		t.Fatalf("balance is %s after a replayed deposit, want 12.50 SGD", got)
+	}
+}
+
+func TestSecureChannelRejectsWrongServerKey(t *testing.T) {
+	key, err := transport.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := transport.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pc, _ := startServer(t, config.UDP, "127.0.0.1:0", key)
+
+	_, conn, _, err := dialSecure(t, pc.LocalAddr(), other.PublicKey().Bytes())
+	if err == nil {
+		conn.Close()
+		t.Fatal("handshake succeeded with the wrong server key pinned")
+	}
+	if !strings.Contains(err.Error(), transport.FormatKey(key.PublicKey().Bytes())) {
+		t.Fatalf("error %q does not name the key the server presented", err)
+	}
+}
+
+// A flood of hellos, which need no keys to send, must not lock out a client that completed its handshake
+func TestSecureChannelSurvivesHelloFlood(t *testing.T) {
+	key, err := transport.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pc, _ := startServer(t, config.UDP, "127.0.0.1:0", key)
+
+	_, conn, _, err := dialSecure(t, pc.LocalAddr(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := newClient(conn)
+	openAccount(t, c, 1000)
+
+	flood, err := net.Dial("udp", pc.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer flood.Close()
+	sender := transport.NewChecksumConn(transport.ConnPacketConn(flood))
+	replies := make(chan struct{})
+	go func() {
+		defer close(replies)
+		buf := make([]byte, transport.MaxDatagramSize)
+		for {
+			flood.SetReadDeadline(time.Now().Add(time.Second))
+			if _, _, err := sender.ReadFrom(buf); err != nil {
+				var checksumErr *transport.ChecksumError
+				if errors.As(err, &checksumErr) {
+					continue
+				}
+				return
+			}
+		}
+	}()
+
+	// Enough hellos to fill the server's session table, paced so the socket does not drop most of them
+	const hellos = 5000
+	for i := 0; i < hellos; i++ {
+		ephemeral, err := transport.GenerateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		hello := append([]byte{0xA1}, ephemeral.PublicKey().Bytes()...)
+		if _, err := sender.WriteTo(hello, flood.RemoteAddr()); err != nil {
+			t.Fatal(err)
+		}
+		if i%64 == 0 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	<-replies
+
+	if got := balance(t, c, 1); got != apiModels.NewMoney(1000, "SGD") {
+		t.Fatalf("balance is %s, want 10.00 SGD", got)
+	}
+	if _, _, _, err := dialSecure(t, pc.LocalAddr(), nil); err != nil {
+		t.Fatalf("new client rejected after the flood: %s", err)
+	}
+}
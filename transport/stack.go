@@ -0,0 +1,43 @@
+package transport
+
+import (
+	"crypto/ecdh"
+	"net"
+
+	"github.com/chiahsoon/cz4013-client/config"
+)
+
+// NewClientConn layers the framing shared with the server over a connection to it
+func NewClientConn(conn net.Conn) net.Conn {
+	pc := NewChecksumConn(ConnPacketConn(conn))
+	return PacketConnConn(NewFragmentConn(pc), conn.RemoteAddr())
+}
+
+// NewServerConn layers the framing shared with clients over the server's socket
+func NewServerConn(pc net.PacketConn) net.PacketConn {
+	return NewFragmentConn(NewChecksumConn(pc))
+}
+
+// NewSecureClientConn is NewClientConn with every datagram sealed, see SecureConn. It returns the server's
+// static key, which must equal pinned unless pinned is nil.
+func NewSecureClientConn(conn net.Conn, pinned []byte) (net.Conn, []byte, error) {
+	sc, serverKey, err := DialSecure(NewChecksumConn(ConnPacketConn(conn)), conn.RemoteAddr(), pinned)
+	if err != nil {
+		return nil, nil, err
+	}
+	return PacketConnConn(NewFragmentConn(sc), conn.RemoteAddr()), serverKey, nil
+}
+
+// NewSecureServerConn is NewServerConn for clients using NewSecureClientConn
+func NewSecureServerConn(pc net.PacketConn, static *ecdh.PrivateKey) net.PacketConn {
+	return NewFragmentConn(ListenSecure(NewChecksumConn(pc), static))
+}
+
+// LayerClientConn is NewSecureClientConn if sec is enabled and NewClientConn otherwise. The server's static key
+// is only returned for a secure channel.
+func LayerClientConn(conn net.Conn, sec config.SecureChannel) (net.Conn, []byte, error) {
+	if !sec.Enabled {
+		return NewClientConn(conn), nil, nil
+	}
+	return NewSecureClientConn(conn, sec.ServerKey)
+}
@@ -0,0 +1,395 @@
+package transport
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// Frames on a TCP stream are the message length as 4 big endian bytes, followed by the message
+const frameHeader = 4
+
+// StreamError is returned by TCPTransport when its connection ends. The next message is sent on a new one.
+type StreamError struct {
+	Addr net.Addr
+	Err  error
+}
+
+func (e *StreamError) Error() string {
+	return fmt.Sprintf("connection to %s ended: %s", e.Addr, e.Err)
+}
+
+func (e *StreamError) Unwrap() error {
+	return e.Err
+}
+
+// TCPTransport frames messages on a stream. Messages still go through the same layers as over UDP, so
+// retransmissions and duplicate filtering behave the same, only loss and reordering on the wire are gone.
+/*
+	- The connection is set up when the first message is sent, and again after it ends, so that a server that is
+	  down or restarts fails requests as it would over UDP instead of the whole transport
+	- Frames are read in the background, so that a deadline passing halfway through one does not lose the rest
+	  of the stream
+*/
+type TCPTransport struct {
+	dial   func() (net.Conn, error)
+	raddr  net.Addr
+	frames chan []byte
+	ended  chan error // Connections that ended, reported once by Receive
+	closed chan struct{}
+	once   sync.Once
+
+	deadline readDeadline
+
+	mu   sync.Mutex // Also keeps frames from concurrent senders from interleaving
+	conn net.Conn   // Nil until dialled, and after the connection ends
+}
+
+// NewTCPTransport calls dial whenever it needs a connection to raddr
+func NewTCPTransport(dial func() (net.Conn, error), raddr net.Addr) *TCPTransport {
+	return &TCPTransport{
+		dial:   dial,
+		raddr:  raddr,
+		frames: make(chan []byte),
+		ended:  make(chan error, 1),
+		closed: make(chan struct{}),
+	}
+}
+
+func (t *TCPTransport) Send(msg []byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	select {
+	case <-t.closed:
+		return net.ErrClosed
+	default:
+	}
+
+	if t.conn == nil {
+		conn, err := t.dial()
+		if err != nil {
+			return err
+		}
+		t.conn = conn
+		go t.read(conn)
+	}
+
+	if err := writeFrame(t.conn, msg); err != nil {
+		t.conn.Close()
+		t.conn = nil
+		return err
+	}
+	return nil
+}
+
+// Receive waits until deadline, or until the one set by a later SetReadDeadline
+func (t *TCPTransport) Receive(buf []byte, deadline time.Time) (int, error) {
+	_, changed := t.deadline.get()
+	for {
+		timeout, stop := deadlineTimer(deadline)
+		select {
+		case frame := <-t.frames:
+			stop()
+			return copy(buf, frame), nil
+		case err := <-t.ended:
+			stop()
+			return 0, err
+		case <-t.closed:
+			stop()
+			return 0, net.ErrClosed
+		case <-timeout:
+			return 0, os.ErrDeadlineExceeded
+		case <-changed:
+			stop()
+			deadline, changed = t.deadline.get()
+		}
+	}
+}
+
+// SetReadDeadline moves the deadline of a Receive already waiting. The connection itself has none, see read.
+func (t *TCPTransport) SetReadDeadline(deadline time.Time) error {
+	t.deadline.set(deadline)
+	return nil
+}
+
+func (t *TCPTransport) Close() error {
+	t.once.Do(func() { close(t.closed) })
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.conn == nil {
+		return nil
+	}
+	err := t.conn.Close()
+	t.conn = nil
+	return err
+}
+
+// The local address of the current connection, nil if there is none
+func (t *TCPTransport) LocalAddr() net.Addr {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.conn == nil {
+		return nil
+	}
+	return t.conn.LocalAddr()
+}
+
+func (t *TCPTransport) RemoteAddr() net.Addr {
+	return t.raddr
+}
+
+func (t *TCPTransport) read(conn net.Conn) {
+	r := bufio.NewReader(conn)
+	for {
+		frame, err := readFrame(r)
+		if err != nil {
+			// Connections closed by Send or Close have already been dealt with
+			t.mu.Lock()
+			current := t.conn == conn
+			if current {
+				t.conn.Close()
+				t.conn = nil
+			}
+			t.mu.Unlock()
+
+			if current {
+				select {
+				case t.ended <- &StreamError{Addr: t.raddr, Err: err}:
+				default:
+				}
+			}
+			return
+		}
+
+		select {
+		case t.frames <- frame:
+		case <-t.closed:
+			return
+		}
+	}
+}
+
+// Address of a host that may resolve to several IP addresses
+type hostAddr struct {
+	network string
+	address string
+}
+
+func (a hostAddr) Network() string {
+	return a.network
+}
+
+func (a hostAddr) String() string {
+	return a.address
+}
+
+// NewTCPPacketConn serves the connections accepted on l as a single PacketConn, with each connection addressed
+// by its remote address. The server can then handle TCP clients the same way as datagrams, including callbacks
+// to monitoring clients for as long as their connection is open.
+func NewTCPPacketConn(l net.Listener) net.PacketConn {
+	pc := &tcpPacketConn{
+		l:      l,
+		frames: make(chan packet),
+		closed: make(chan struct{}),
+		peers:  map[string]*tcpPeer{},
+	}
+	go pc.accept()
+	return pc
+}
+
+type tcpPacketConn struct {
+	l      net.Listener
+	frames chan packet
+	closed chan struct{}
+	once   sync.Once
+
+	deadline readDeadline
+
+	mu    sync.Mutex
+	peers map[string]*tcpPeer // By remote address
+}
+
+type packet struct {
+	data []byte
+	addr net.Addr
+}
+
+type tcpPeer struct {
+	conn    net.Conn
+	writeMu sync.Mutex
+}
+
+func (pc *tcpPacketConn) accept() {
+	for {
+		conn, err := pc.l.Accept()
+		if err != nil {
+			// The listener is only closed by Close
+			return
+		}
+
+		peer := &tcpPeer{conn: conn}
+		pc.mu.Lock()
+		pc.peers[conn.RemoteAddr().String()] = peer
+		pc.mu.Unlock()
+		go pc.serve(peer)
+	}
+}
+
+// A connection that ends or sends a malformed frame is dropped, the client reconnects if it needs to
+func (pc *tcpPacketConn) serve(peer *tcpPeer) {
+	defer func() {
+		pc.mu.Lock()
+		delete(pc.peers, peer.conn.RemoteAddr().String())
+		pc.mu.Unlock()
+		peer.conn.Close()
+	}()
+
+	r := bufio.NewReader(peer.conn)
+	for {
+		frame, err := readFrame(r)
+		if err != nil {
+			return
+		}
+
+		select {
+		case pc.frames <- packet{data: frame, addr: peer.conn.RemoteAddr()}:
+		case <-pc.closed:
+			return
+		}
+	}
+}
+
+func (pc *tcpPacketConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	deadline, changed := pc.deadline.get()
+	for {
+		timeout, stop := deadlineTimer(deadline)
+		select {
+		case p := <-pc.frames:
+			stop()
+			return copy(b, p.data), p.addr, nil
+		case <-pc.closed:
+			stop()
+			return 0, nil, net.ErrClosed
+		case <-timeout:
+			return 0, nil, os.ErrDeadlineExceeded
+		case <-changed:
+			stop()
+			deadline, changed = pc.deadline.get()
+		}
+	}
+}
+
+func (pc *tcpPacketConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	pc.mu.Lock()
+	peer, ok := pc.peers[addr.String()]
+	pc.mu.Unlock()
+	if !ok {
+		return 0, fmt.Errorf("no connection from %s", addr)
+	}
+
+	peer.writeMu.Lock()
+	defer peer.writeMu.Unlock()
+	if err := writeFrame(peer.conn, b); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+func (pc *tcpPacketConn) Close() error {
+	pc.once.Do(func() { close(pc.closed) })
+	err := pc.l.Close()
+
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	for _, peer := range pc.peers {
+		peer.conn.Close()
+	}
+	return err
+}
+
+func (pc *tcpPacketConn) LocalAddr() net.Addr {
+	return pc.l.Addr()
+}
+
+func (pc *tcpPacketConn) SetDeadline(t time.Time) error {
+	return pc.SetReadDeadline(t)
+}
+
+func (pc *tcpPacketConn) SetReadDeadline(t time.Time) error {
+	pc.deadline.set(t)
+	return nil
+}
+
+func (pc *tcpPacketConn) SetWriteDeadline(t time.Time) error {
+	return nil
+}
+
+// Read deadline of a reader that waits on channels rather than the connection, which needs waking when it moves
+type readDeadline struct {
+	mu      sync.Mutex
+	t       time.Time
+	changed chan struct{} // Closed when t is set
+}
+
+func (d *readDeadline) get() (time.Time, <-chan struct{}) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.changed == nil {
+		d.changed = make(chan struct{})
+	}
+	return d.t, d.changed
+}
+
+func (d *readDeadline) set(t time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.t = t
+	if d.changed != nil {
+		close(d.changed)
+	}
+	d.changed = make(chan struct{})
+}
+
+// A channel that fires at deadline, or never if it is zero, and a func that stops it
+func deadlineTimer(deadline time.Time) (<-chan time.Time, func() bool) {
+	if deadline.IsZero() {
+		return nil, func() bool { return false }
+	}
+	timer := time.NewTimer(time.Until(deadline))
+	return timer.C, timer.Stop
+}
+
+func writeFrame(w io.Writer, msg []byte) error {
+	if len(msg) > MaxMessageSize {
+		return fmt.Errorf("message of %d bytes exceeds the maximum of %d", len(msg), MaxMessageSize)
+	}
+
+	frame := make([]byte, frameHeader+len(msg))
+	binary.BigEndian.PutUint32(frame, uint32(len(msg)))
+	copy(frame[frameHeader:], msg)
+	_, err := w.Write(frame)
+	return err
+}
+
+func readFrame(r *bufio.Reader) ([]byte, error) {
+	header := make([]byte, frameHeader)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+
+	size := binary.BigEndian.Uint32(header)
+	if size > MaxMessageSize {
+		return nil, fmt.Errorf("frame of %d bytes exceeds the maximum of %d", size, MaxMessageSize)
+	}
+	frame := make([]byte, size)
+	if _, err := io.ReadFull(r, frame); err != nil {
+		return nil, err
+	}
+	return frame, nil
+}
@@ -0,0 +1,212 @@
+package transport
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/chiahsoon/cz4013-client/config"
+)
+
+// Transport carries whole messages between the client and the server. The layers above it, such as
+// fragmentation and checksums, are the same whichever transport is used, see TransportConn.
+type Transport interface {
+	Send(msg []byte) error
+	// Receive waits for the next message until deadline, forever if it is zero. Longer messages than buf are
+	// truncated.
+	Receive(buf []byte, deadline time.Time) (int, error)
+	Close() error
+	LocalAddr() net.Addr
+	RemoteAddr() net.Addr
+}
+
+// Transports that can move the deadline of a Receive already waiting
+type deadliner interface {
+	SetReadDeadline(t time.Time) error
+}
+
+// DefaultSocketPath is where the server's unix socket is unless configured otherwise
+func DefaultSocketPath() string {
+	return filepath.Join(os.TempDir(), "cz4013-bank.sock")
+}
+
+// Dial connects to the server over the transport, without trying the other addresses of a host as Dialer does.
+// address is a host and port, or the socket path for the unix transport.
+func Dial(ctx context.Context, transport config.Transport, address string) (net.Conn, error) {
+	switch transport {
+	case config.UDP:
+		raddr, err := net.ResolveUDPAddr("udp", address)
+		if err != nil {
+			return nil, err
+		}
+		conn, err := net.DialUDP("udp", nil, raddr)
+		if err != nil {
+			return nil, err
+		}
+		return TransportConn(NewUDPTransport(conn)), nil
+	case config.TCP:
+		dial := func() (net.Conn, error) { return net.Dial("tcp", address) }
+		return TransportConn(NewTCPTransport(dial, hostAddr{network: "tcp", address: address})), nil
+	case config.Unix:
+		return DialUnix(address)
+	default:
+		return nil, transport.Validate()
+	}
+}
+
+// Listen opens the server's end of the transport. Stale socket files of the unix transport are replaced.
+func Listen(transport config.Transport, address string) (net.PacketConn, error) {
+	switch transport {
+	case config.UDP:
+		return net.ListenPacket("udp", address)
+	case config.TCP:
+		l, err := net.Listen("tcp", address)
+		if err != nil {
+			return nil, err
+		}
+		return NewTCPPacketConn(l), nil
+	case config.Unix:
+		if err := os.Remove(address); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		return net.ListenUnixgram("unixgram", &net.UnixAddr{Name: address, Net: "unixgram"})
+	default:
+		return nil, transport.Validate()
+	}
+}
+
+// DialUnix sets up a unix datagram socket for the server's socket at path. The client binds a socket file of its
+// own in the temporary directory for replies, which is removed when the connection is closed. As with UDP, the
+// server does not need to be up yet.
+func DialUnix(path string) (net.Conn, error) {
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return nil, err
+	}
+	local := filepath.Join(os.TempDir(), fmt.Sprintf("cz4013-client-%d-%s.sock", os.Getpid(), hex.EncodeToString(suffix)))
+
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: local, Net: "unixgram"})
+	if err != nil {
+		return nil, err
+	}
+	return TransportConn(NewUnixTransport(conn, &net.UnixAddr{Name: path, Net: "unixgram"}, local)), nil
+}
+
+// Each message is one datagram
+type udpTransport struct {
+	*net.UDPConn
+}
+
+func NewUDPTransport(conn *net.UDPConn) Transport {
+	return &udpTransport{conn}
+}
+
+func (t *udpTransport) Send(msg []byte) error {
+	_, err := t.UDPConn.Write(msg)
+	return err
+}
+
+func (t *udpTransport) Receive(buf []byte, deadline time.Time) (int, error) {
+	if err := t.UDPConn.SetReadDeadline(deadline); err != nil {
+		return 0, err
+	}
+	return t.UDPConn.Read(buf)
+}
+
+// Each message is one datagram to raddr, sent from a socket that is bound but not connected
+type unixTransport struct {
+	*net.UnixConn
+	raddr *net.UnixAddr
+	path  string // Socket file of conn, removed on Close
+}
+
+// NewUnixTransport sends from conn to raddr, and removes the socket file at path once closed
+func NewUnixTransport(conn *net.UnixConn, raddr *net.UnixAddr, path string) Transport {
+	return &unixTransport{UnixConn: conn, raddr: raddr, path: path}
+}
+
+func (t *unixTransport) Send(msg []byte) error {
+	_, err := t.UnixConn.WriteToUnix(msg, t.raddr)
+	return err
+}
+
+// Only the server knows the client's socket, so every datagram is from it
+func (t *unixTransport) Receive(buf []byte, deadline time.Time) (int, error) {
+	if err := t.UnixConn.SetReadDeadline(deadline); err != nil {
+		return 0, err
+	}
+	n, _, err := t.UnixConn.ReadFromUnix(buf)
+	return n, err
+}
+
+func (t *unixTransport) RemoteAddr() net.Addr {
+	return t.raddr
+}
+
+func (t *unixTransport) Close() error {
+	err := t.UnixConn.Close()
+	os.Remove(t.path)
+	return err
+}
+
+// TransportConn adapts t into a conn, so that the layers written against net.Conn run over any transport
+func TransportConn(t Transport) net.Conn {
+	return &transportConn{t: t}
+}
+
+type transportConn struct {
+	t            Transport
+	mu           sync.Mutex
+	readDeadline time.Time
+}
+
+func (c *transportConn) Read(b []byte) (int, error) {
+	c.mu.Lock()
+	deadline := c.readDeadline
+	c.mu.Unlock()
+	return c.t.Receive(b, deadline)
+}
+
+func (c *transportConn) Write(b []byte) (int, error) {
+	if err := c.t.Send(b); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+func (c *transportConn) Close() error {
+	return c.t.Close()
+}
+
+func (c *transportConn) LocalAddr() net.Addr {
+	return c.t.LocalAddr()
+}
+
+func (c *transportConn) RemoteAddr() net.Addr {
+	return c.t.RemoteAddr()
+}
+
+func (c *transportConn) SetDeadline(t time.Time) error {
+	return c.SetReadDeadline(t)
+}
+
+func (c *transportConn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	c.readDeadline = t
+	c.mu.Unlock()
+	if d, ok := c.t.(deadliner); ok {
+		return d.SetReadDeadline(t)
+	}
+	return nil
+}
+
+// Sends do not block for long on any transport
+func (c *transportConn) SetWriteDeadline(t time.Time) error {
+	return nil
+}
@@ -0,0 +1,130 @@
+package transport_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	apiModels "github.com/chiahsoon/cz4013-client/api/models"
+	"github.com/chiahsoon/cz4013-client/config"
+	"github.com/chiahsoon/cz4013-client/transport"
+)
+
+// Runs the in-repo server on a unix socket in a directory of the test's own
+func startUnixServer(t *testing.T, secure bool) (string, []byte) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "bank.sock")
+	if !secure {
+		startServer(t, config.Unix, path, nil)
+		return path, nil
+	}
+
+	key, err := transport.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	startServer(t, config.Unix, path, key)
+	return path, key.PublicKey().Bytes()
+}
+
+func TestUnixTransport(t *testing.T) {
+	for _, secure := range []bool{false, true} {
+		t.Run(fmt.Sprintf("secure=%t", secure), func(t *testing.T) {
+			path, serverKey := startUnixServer(t, secure)
+			conn, err := transport.Dial(context.Background(), config.Unix, path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			layered, _, err := transport.LayerClientConn(conn, config.SecureChannel{Enabled: secure, ServerKey: serverKey})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			c := newClient(layered)
+			openAccount(t, c, 1000)
+			req := apiModels.UpdateBalanceReq{AccountNumber: 1, Name: "Alice Tan", Password: "hunter22", Amount: apiModels.NewMoney(250, "SGD")}
+			if _, err := c.Withdraw(context.Background(), req); err != nil {
+				t.Fatal(err)
+			}
+			if got := balance(t, c, 1); got != apiModels.NewMoney(750, "SGD") {
+				t.Fatalf("balance is %s, want 7.50 SGD", got)
+			}
+		})
+	}
+}
+
+// Replies larger than a datagram are fragmented, and every fragment must find its way back to the client's socket
+func TestUnixTransportFragmentedReply(t *testing.T) {
+	path, _ := startUnixServer(t, false)
+	conn, err := transport.Dial(context.Background(), config.Unix, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	c := newClient(transport.NewClientConn(conn))
+	const accounts = 50
+	for i := 0; i < accounts; i++ {
+		openAccount(t, c, int64(i))
+	}
+	state, err := c.CheckState(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(state) != accounts {
+		t.Fatalf("check-state returned %d accounts, want %d", len(state), accounts)
+	}
+}
+
+func TestUnixTransportRemovesClientSocket(t *testing.T) {
+	path, _ := startUnixServer(t, false)
+	before, err := filepath.Glob(filepath.Join(os.TempDir(), fmt.Sprintf("cz4013-client-%d-*.sock", os.Getpid())))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := transport.Dial(context.Background(), config.Unix, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	local := conn.LocalAddr().String()
+	if _, err := os.Stat(local); err != nil {
+		t.Fatalf("client socket missing while open: %s", err)
+	}
+	conn.Close()
+
+	if _, err := os.Stat(local); !os.IsNotExist(err) {
+		t.Fatalf("client socket %s left behind after Close", local)
+	}
+	after, err := filepath.Glob(filepath.Join(os.TempDir(), fmt.Sprintf("cz4013-client-%d-*.sock", os.Getpid())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(after) > len(before) {
+		t.Fatalf("client sockets left behind: %v", after)
+	}
+}
+
+// A socket file left by a server that did not shut down cleanly is replaced rather than failing Listen
+func TestUnixListenReplacesStaleSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bank.sock")
+	stale, err := transport.Listen(config.Unix, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.Close()
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("closing the socket removed its file: %s", err)
+	}
+
+	startServer(t, config.Unix, path, nil)
+	conn, err := transport.Dial(context.Background(), config.Unix, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	openAccount(t, newClient(transport.NewClientConn(conn)), 1000)
+}